@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"zid-packages/internal/autoupdate"
+	"zid-packages/internal/catalog"
+	"zid-packages/internal/packages"
+)
+
+// dryRunPackage imprime o que install/update fariam e devolve o exit code:
+// 1 se algum passo falharia. Roda no proprio processo (nao vira job) e nao
+// toma locks nem grava estado, nem mesmo o cache do catalogo.
+func dryRunPackage(action, key, fromFile string, force, allowUnsigned, jsonOut bool) int {
+	catalog.SetReadOnly(true)
+	var steps []packages.PlanStep
+	var err error
+	switch {
+	case fromFile != "":
+		if fromFile, err = filepath.Abs(fromFile); err == nil {
+			steps, err = packages.PlanFromFileSteps(key, action, fromFile)
+		}
+	case action == "install":
+		steps, err = packages.PlanInstallSteps(key)
+	default:
+		var step packages.PlanStep
+		step, err = packages.PlanUpdateStep(key, allowUnsigned)
+		steps = []packages.PlanStep{step}
+	}
+	if err != nil {
+		reportError(err)
+		return 1
+	}
+	if action == "update" && !force {
+		if err := checkHold(key); err != nil {
+			last := &steps[len(steps)-1]
+			last.Action = packages.PlanSkip
+			last.Reason = err.Error()
+		}
+	}
+	if jsonOut {
+		if err := printJSON(steps); err != nil {
+			reportError(err)
+			return 1
+		}
+	} else {
+		printPlan(steps)
+	}
+	if packages.PlanFailed(steps) {
+		return 1
+	}
+	return 0
+}
+
+func dryRunAutoUpdate(now time.Time, jsonOut bool) int {
+	catalog.SetReadOnly(true)
+	plan, err := autoupdate.PlanOnce(now)
+	if err != nil {
+		reportError(err)
+		return 1
+	}
+	if jsonOut {
+		if err := printJSON(plan); err != nil {
+			reportError(err)
+			return 1
+		}
+	} else {
+		fmt.Println("proxima janela do daemon: " + time.Unix(plan.NextRunAt, 0).Local().Format(time.RFC3339))
+		if plan.Blocked != "" {
+			fmt.Println("rodada aguardaria o lock global: " + plan.Blocked)
+		}
+		printPlan(plan.Steps)
+	}
+	if packages.PlanFailed(plan.Steps) {
+		return 1
+	}
+	return 0
+}
+
+func printPlan(steps []packages.PlanStep) {
+	if len(steps) == 0 {
+		fmt.Println("nada a fazer")
+		return
+	}
+	for _, step := range steps {
+		fmt.Println(step.String())
+	}
+	fmt.Fprintln(os.Stderr, "dry-run: nenhuma alteracao feita")
+}
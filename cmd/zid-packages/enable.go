@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"zid-packages/internal/ipc"
+	"zid-packages/internal/packages"
+)
+
+func handleEnable(args []string) {
+	if len(args) < 2 || args[0] != "explain" {
+		usage()
+		os.Exit(2)
+	}
+	key := strings.TrimSpace(args[1])
+	fs := flag.NewFlagSet("enable explain", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "saida em JSON")
+	if err := fs.Parse(args[2:]); err != nil || fs.NArg() != 0 || key == "" {
+		usage()
+		os.Exit(2)
+	}
+	exp, err := explainEnabled(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if *jsonFlag {
+		if err := printJSON(exp); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	printExplanation(exp)
+}
+
+// explainEnabled pergunta ao daemon (cache do watchdog) e, sem daemon,
+// resolve localmente.
+func explainEnabled(key string) (packages.EnableExplanation, error) {
+	resp, err := ipc.Call(ipc.Request{Op: ipc.OpEnableExplain, Package: key})
+	if err == nil && resp.Explain != nil {
+		return *resp.Explain, nil
+	}
+	var rerr *ipc.RemoteError
+	if errors.As(err, &rerr) && rerr.Reason == "unknown_package" {
+		return packages.EnableExplanation{}, fmt.Errorf("pacote ou servico desconhecido: %s", key)
+	}
+	exp, lerr := packages.ExplainEnabled(key)
+	if lerr != nil {
+		return exp, lerr
+	}
+	exp.Origin = "local"
+	return exp, nil
+}
+
+func printExplanation(exp packages.EnableExplanation) {
+	fmt.Printf("%s: enabled=%t (fonte: %s)\n", exp.Key, exp.Enabled, exp.Source)
+	if exp.Chain != exp.Key {
+		fmt.Println("cadeia: " + exp.Chain + " (servico sem cadeia propria)")
+	}
+	if exp.Origin == "local" {
+		fmt.Println("daemon indisponivel: resolvido neste processo, sem o cache do watchdog")
+	}
+	if exp.CacheEnabled {
+		cache := "vazio"
+		if exp.CacheHit {
+			cache = "enabled=" + strconv.FormatBool(exp.CacheValue) + " ha " + strconv.FormatInt(exp.CacheAgeSeconds, 10) + "s"
+		}
+		used := "nao usado"
+		if exp.CacheUsed {
+			used = "usado: nenhuma fonte respondeu"
+		}
+		fmt.Println("cache (2 min): " + cache + ", " + used)
+	}
+	for _, src := range exp.Sources {
+		mark := "  "
+		if src.Winner {
+			mark = "* "
+		}
+		line := mark + src.Label + ": "
+		if src.Responded {
+			line += strconv.Quote(src.Value) + " -> " + onOff(src.Enabled)
+		} else {
+			line += "sem resposta"
+		}
+		if !src.Consulted {
+			line += " (depois da vencedora; ignorada)"
+		}
+		fmt.Println(line)
+	}
+	if exp.Source == packages.EnableSourceDefault {
+		fmt.Println("nenhuma fonte respondeu: tratado como desabilitado")
+	}
+}
+
+func onOff(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"zid-packages/internal/ipc"
+	"zid-packages/internal/jobs"
+)
+
+const (
+	followInterval = 1 * time.Second
+	// Durante o update do proprio zid-packages o daemon reinicia; o follow
+	// espera ele voltar em vez de abortar.
+	followReconnect = 3 * time.Minute
+)
+
+// inJob indica que este processo e o executor de um job do daemon.
+func inJob() bool {
+	return os.Getenv(jobs.EnvJobID) != ""
+}
+
+// submitPackageJob envia install/update ao daemon e acompanha a saida; devolve
+// o exit code do job.
+func submitPackageJob(action, key, fromFile string, force, allowUnsigned, detach bool) (int, error) {
+	req := ipc.Request{Op: ipc.OpJobSubmit, Action: action, Package: key, FromFile: fromFile, Force: force, AllowUnsigned: allowUnsigned}
+	resp, err := ipc.Call(req)
+	if err != nil {
+		var rerr *ipc.RemoteError
+		if errors.As(err, &rerr) && rerr.Reason == "job_active" && rerr.Resp.Job != nil {
+			return 1, fmt.Errorf("ja existe job ativo para %s: %s", key, rerr.Resp.Job.ID)
+		}
+		return 1, err
+	}
+	if detach {
+		fmt.Println(resp.Job.ID)
+		return 0, nil
+	}
+	fmt.Fprintln(os.Stderr, "job "+resp.Job.ID)
+	return followJob(resp.Job.ID)
+}
+
+func followJob(id string) (int, error) {
+	offset := int64(0)
+	var unavailableSince time.Time
+	for {
+		resp, err := ipc.Call(ipc.Request{Op: ipc.OpJobLogs, JobID: id, Offset: offset})
+		if err != nil {
+			if !errors.Is(err, ipc.ErrDaemonUnavailable) {
+				return 1, err
+			}
+			if unavailableSince.IsZero() {
+				unavailableSince = time.Now()
+			}
+			if time.Since(unavailableSince) > followReconnect {
+				return 1, err
+			}
+			time.Sleep(followInterval)
+			continue
+		}
+		unavailableSince = time.Time{}
+		os.Stdout.Write(resp.Output)
+		offset = resp.Offset
+		if len(resp.Output) > 0 {
+			continue
+		}
+		if resp.Job != nil && resp.Job.Finished() {
+			return jobExitCode(*resp.Job), nil
+		}
+		time.Sleep(followInterval)
+	}
+}
+
+func jobExitCode(job jobs.Job) int {
+	switch {
+	case job.State == jobs.StateSucceeded:
+		return 0
+	case job.ExitCode != nil && *job.ExitCode > 0:
+		return *job.ExitCode
+	default:
+		return 1
+	}
+}
+
+func handleJobs(args []string) {
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("jobs "+action, flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "saida em JSON")
+	follow := fs.Bool("follow", false, "acompanha a saida ate o job terminar")
+	if err := fs.Parse(args[1:]); err != nil {
+		usage()
+		os.Exit(2)
+	}
+	wantID := action != "list"
+	if (wantID && fs.NArg() != 1) || (!wantID && fs.NArg() != 0) || (*follow && action != "logs") {
+		usage()
+		os.Exit(2)
+	}
+	id := fs.Arg(0)
+
+	var err error
+	code := 0
+	switch action {
+	case "list":
+		err = listJobs(*jsonFlag)
+	case "show":
+		var resp ipc.Response
+		if resp, err = ipc.Call(ipc.Request{Op: ipc.OpJobShow, JobID: id}); err == nil {
+			err = printJSON(resp.Job)
+		}
+	case "cancel":
+		var resp ipc.Response
+		if resp, err = ipc.Call(ipc.Request{Op: ipc.OpJobCancel, JobID: id}); err == nil {
+			fmt.Println(id + " " + resp.Job.State)
+		}
+	case "logs":
+		if *follow {
+			code, err = followJob(id)
+		} else {
+			err = printJobLogs(id)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		reportError(err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func listJobs(jsonOut bool) error {
+	resp, err := ipc.Call(ipc.Request{Op: ipc.OpJobList})
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		if resp.Jobs == nil {
+			resp.Jobs = []jobs.Job{}
+		}
+		return printJSON(resp.Jobs)
+	}
+	for _, job := range resp.Jobs {
+		exit := "-"
+		if job.ExitCode != nil {
+			exit = fmt.Sprint(*job.ExitCode)
+		}
+		fmt.Printf("%s  %-9s  %-7s  %-16s  %s  exit=%s\n", job.ID, job.State, job.Action, job.Package,
+			time.Unix(job.CreatedAt, 0).Format("2006-01-02 15:04:05"), exit)
+	}
+	return nil
+}
+
+func printJobLogs(id string) error {
+	offset := int64(0)
+	for {
+		resp, err := ipc.Call(ipc.Request{Op: ipc.OpJobLogs, JobID: id, Offset: offset})
+		if err != nil {
+			return err
+		}
+		if len(resp.Output) == 0 {
+			return nil
+		}
+		os.Stdout.Write(resp.Output)
+		offset = resp.Offset
+	}
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -3,12 +3,16 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"zid-packages/internal/autoupdate"
+	"zid-packages/internal/download"
+	"zid-packages/internal/ipc"
 	"zid-packages/internal/licensing"
 	"zid-packages/internal/logx"
 	"zid-packages/internal/packages"
@@ -43,6 +47,12 @@ func main() {
 		handleDaemon(logger, os.Args[2:])
 	case "auto-update":
 		handleAutoUpdate(logger, os.Args[2:])
+	case "jobs":
+		handleJobs(os.Args[2:])
+	case "enable":
+		handleEnable(os.Args[2:])
+	case "service":
+		handleService(logger, os.Args[2:])
 	default:
 		usage()
 		os.Exit(2)
@@ -55,9 +65,19 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "  status [--json]")
 	fmt.Fprintln(os.Stderr, "  watchdog --once")
 	fmt.Fprintln(os.Stderr, "  license sync")
-	fmt.Fprintln(os.Stderr, "  package install <pkg>")
-	fmt.Fprintln(os.Stderr, "  package update <pkg>")
-	fmt.Fprintln(os.Stderr, "  auto-update --once")
+	fmt.Fprintln(os.Stderr, "  package install <pkg> [--from-file <bundle.tar.gz>] [--detach | --dry-run [--json]]")
+	fmt.Fprintln(os.Stderr, "  package update <pkg> [--from-file <bundle.tar.gz> | --allow-unsigned] [--force] [--detach | --dry-run [--json]]")
+	fmt.Fprintln(os.Stderr, "  package uninstall <pkg>")
+	fmt.Fprintln(os.Stderr, "  package rollback <pkg> [--to <version>]")
+	fmt.Fprintln(os.Stderr, "  package hold <pkg> [version]")
+	fmt.Fprintln(os.Stderr, "  package unhold <pkg>")
+	fmt.Fprintln(os.Stderr, "  package channel <pkg> [stable|beta|lts|<canal>]")
+	fmt.Fprintln(os.Stderr, "  jobs list [--json]")
+	fmt.Fprintln(os.Stderr, "  jobs show|cancel <id>")
+	fmt.Fprintln(os.Stderr, "  jobs logs <id> [--follow]")
+	fmt.Fprintln(os.Stderr, "  enable explain <pkg|service> [--json]")
+	fmt.Fprintln(os.Stderr, "  service enable|disable <service>")
+	fmt.Fprintln(os.Stderr, "  auto-update --once [--dry-run [--json]]")
 	fmt.Fprintln(os.Stderr, "  daemon")
 }
 
@@ -99,7 +119,7 @@ func handleLicense(logger *logx.Logger, args []string) {
 }
 
 func handlePackage(logger *logx.Logger, args []string) {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		usage()
 		os.Exit(2)
 	}
@@ -114,22 +134,170 @@ func handlePackage(logger *logx.Logger, args []string) {
 		os.Exit(2)
 	}
 
+	fs := flag.NewFlagSet("package "+action, flag.ContinueOnError)
+	fromFile := fs.String("from-file", "", "bundle local (.tar.gz) com .sig ao lado")
+	to := fs.String("to", "", "versao alvo do rollback (cache de bundles)")
+	force := fs.Bool("force", false, "ignora hold no update")
+	allowUnsigned := fs.Bool("allow-unsigned", false, "roda o update_command de pacote sem bundle assinado")
+	detach := fs.Bool("detach", false, "apenas enfileira o job no daemon e imprime o id")
+	dryRun := fs.Bool("dry-run", false, "mostra o plano sem baixar bundles nem rodar scripts")
+	jsonOut := fs.Bool("json", false, "plano do --dry-run em JSON")
+	if err := fs.Parse(args[2:]); err != nil {
+		usage()
+		os.Exit(2)
+	}
+	maxArgs := 0
+	if action == "hold" || action == "channel" {
+		maxArgs = 1
+	}
+	if fs.NArg() > maxArgs || (*to != "" && action != "rollback") || (*force && action != "update") {
+		usage()
+		os.Exit(2)
+	}
+	if *allowUnsigned && (action != "update" || *fromFile != "") {
+		usage()
+		os.Exit(2)
+	}
+	if (*fromFile != "" || *detach || *dryRun) && action != "install" && action != "update" {
+		usage()
+		os.Exit(2)
+	}
+	if (*jsonOut && !*dryRun) || (*dryRun && *detach) {
+		usage()
+		os.Exit(2)
+	}
+
 	var err error
 	switch action {
-	case "install":
-		err = packages.Install(logger, key)
-	case "update":
-		err = packages.Update(logger, key)
+	case "install", "update":
+		if *dryRun {
+			os.Exit(dryRunPackage(action, key, *fromFile, *force, *allowUnsigned, *jsonOut))
+		}
+		if action == "update" && !*force {
+			if err := checkHold(key); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+		if !inJob() {
+			path := *fromFile
+			if path != "" {
+				if path, err = filepath.Abs(path); err != nil {
+					reportError(err)
+					os.Exit(1)
+				}
+			}
+			code, err := submitPackageJob(action, key, path, *force, *allowUnsigned, *detach)
+			if err == nil {
+				os.Exit(code)
+			}
+			if *detach || !errors.Is(err, ipc.ErrDaemonUnavailable) {
+				reportError(err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(os.Stderr, "daemon indisponivel; executando localmente")
+		}
+		if *fromFile != "" {
+			err = packages.InstallFromFile(logger, key, action, *fromFile)
+		} else if action == "install" {
+			err = packages.Install(logger, key)
+		} else {
+			err = packages.Update(logger, key, *allowUnsigned)
+		}
+	case "uninstall":
+		err = uninstallPackage(logger, key)
+	case "rollback":
+		err = packages.Rollback(logger, key, *to)
+	case "hold":
+		err = holdPackage(logger, key, fs.Arg(0))
+	case "unhold":
+		err = unholdPackage(logger, key)
+	case "channel":
+		err = packageChannel(logger, key, fs.Arg(0))
 	default:
 		usage()
 		os.Exit(2)
 	}
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+		reportError(err)
 		os.Exit(1)
 	}
 }
 
+func uninstallPackage(logger *logx.Logger, key string) error {
+	if err := packages.Uninstall(logger, key); err != nil {
+		return err
+	}
+	if err := autoupdate.Forget(key); err != nil {
+		logger.Error("uninstall: falha ao limpar auto-update: " + key + " err=" + err.Error())
+	}
+	if err := licensing.Forget(key); err != nil {
+		logger.Error("uninstall: falha ao limpar licenca: " + key + " err=" + err.Error())
+	}
+	return nil
+}
+
+func checkHold(key string) error {
+	st, err := autoupdate.Load()
+	if err != nil {
+		return err
+	}
+	if hold, ok := autoupdate.HoldFor(st, key); ok {
+		return fmt.Errorf("%s em hold (pinned=%s); use --force para atualizar", key, hold.Version)
+	}
+	return nil
+}
+
+func holdPackage(logger *logx.Logger, key, version string) error {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		version = packages.VersionLocal(key)
+	}
+	if err := autoupdate.SetHold(key, version, time.Now().UTC()); err != nil {
+		return err
+	}
+	logger.Info("hold: " + key + " pinned=" + version)
+	fmt.Println(key + " em hold (pinned=" + version + ")")
+	return nil
+}
+
+func unholdPackage(logger *logx.Logger, key string) error {
+	removed, err := autoupdate.ClearHold(key)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		fmt.Println(key + " nao estava em hold")
+		return nil
+	}
+	logger.Info("unhold: " + key)
+	fmt.Println(key + " liberado para auto-update")
+	return nil
+}
+
+func packageChannel(logger *logx.Logger, key, channel string) error {
+	if channel == "" {
+		fmt.Println(packages.Channel(key))
+		return nil
+	}
+	if err := packages.SetChannel(key, channel); err != nil {
+		return err
+	}
+	logger.Info("channel: " + key + " " + packages.Channel(key))
+	fmt.Println(key + " no canal " + packages.Channel(key))
+	return nil
+}
+
+// reportError imprime o erro e, para falhas de download, uma linha
+// "error_kind=" estavel para a GUI nao depender do texto.
+func reportError(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	var derr *download.Error
+	if errors.As(err, &derr) {
+		fmt.Fprintln(os.Stderr, "error_kind="+derr.Kind)
+	}
+}
+
 func handleDaemon(logger *logx.Logger, args []string) {
 	if len(args) != 0 {
 		usage()
@@ -144,10 +312,17 @@ func handleDaemon(logger *logx.Logger, args []string) {
 }
 
 func handleAutoUpdate(logger *logx.Logger, args []string) {
-	if len(args) != 1 || args[0] != "--once" {
+	fs := flag.NewFlagSet("auto-update", flag.ContinueOnError)
+	once := fs.Bool("once", false, "executa uma rodada agora")
+	dryRun := fs.Bool("dry-run", false, "mostra o plano da rodada sem executar")
+	jsonOut := fs.Bool("json", false, "plano do --dry-run em JSON")
+	if err := fs.Parse(args); err != nil || !*once || fs.NArg() > 0 || (*jsonOut && !*dryRun) {
 		usage()
 		os.Exit(2)
 	}
 	now := time.Now().UTC()
+	if *dryRun {
+		os.Exit(dryRunAutoUpdate(now, *jsonOut))
+	}
 	autoupdate.RunOnce(logger, now)
 }
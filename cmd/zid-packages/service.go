@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"zid-packages/internal/logx"
+	"zid-packages/internal/packages"
+	"zid-packages/internal/watchdog"
+)
+
+// handleService grava o enable (config.xml via write_config, rc.conf.local ou
+// JSON do pacote) e reconcilia em seguida os servicos afetados.
+func handleService(logger *logx.Logger, args []string) {
+	if len(args) != 2 || (args[0] != "enable" && args[0] != "disable") {
+		usage()
+		os.Exit(2)
+	}
+	action, key := args[0], strings.TrimSpace(args[1])
+	if key == "" {
+		usage()
+		os.Exit(2)
+	}
+	chainKey, target, err := packages.SetEnabled(logger, key, action == "enable")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(key + ": " + action + " gravado em " + target)
+	affected := packages.ServicesForEnable(chainKey)
+	if chainKey != key {
+		fmt.Println("enable decidido por " + chainKey + "; afeta: " + strings.Join(affected, ", "))
+	}
+	if err := watchdog.RunServices(logger, affected); err != nil {
+		fmt.Fprintln(os.Stderr, "reconciliacao falhou: "+err.Error())
+		os.Exit(1)
+	}
+}
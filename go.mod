@@ -2,4 +2,4 @@ module zid-packages
 
 go 1.20
 
-require golang.org/x/crypto v0.17.0 // indirect
+require golang.org/x/crypto v0.17.0
@@ -5,24 +5,39 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"zid-packages/internal/lock"
 )
 
 const (
-	StatePath      = "/var/db/zid-packages/auto-update.json"
 	MinDays        = 0
 	daySeconds     = 24 * time.Hour
 	ScheduleHour   = 23
 	ScheduleMinute = 59
+	// stateLock serializa o load-modify-save do auto-update.json entre CLI,
+	// status e a rodada do daemon; e segurado so durante a gravacao.
+	stateLock     = "auto-update-state"
+	stateLockWait = 10 * time.Second
 )
 
+var StatePath = "/var/db/zid-packages/auto-update.json"
+
 type Entry struct {
 	Version   string `json:"version"`
 	FirstSeen int64  `json:"first_seen"`
 	LastSeen  int64  `json:"last_seen"`
 }
 
+// Hold impede o auto-update do pacote; Version e a versao fixada (a
+// instalada no momento do hold quando nao informada).
+type Hold struct {
+	Version string `json:"version,omitempty"`
+	Since   int64  `json:"since"`
+}
+
 type State struct {
 	Packages   map[string]Entry `json:"packages"`
+	Holds      map[string]Hold  `json:"holds,omitempty"`
 	LastRunDay string           `json:"last_run_day"`
 }
 
@@ -54,7 +69,48 @@ func Save(st State) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(StatePath, data, 0600)
+	tmp := StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, StatePath)
+}
+
+// Modify aplica fn sobre o auto-update.json atual, sob o lock do arquivo, e
+// grava se fn devolver true.
+func Modify(fn func(st *State) bool) error {
+	l, err := lock.AcquireWait(stateLock, "auto-update.json", stateLockWait)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+	if !fn(&st) {
+		return nil
+	}
+	return Save(st)
+}
+
+// SavePackages grava as entradas de keys de st (e LastRunDay, se lastRun)
+// sobre o arquivo atual: holds feitos enquanto st estava em memoria, durante
+// uma rodada longa, nao sao sobrescritos.
+func SavePackages(st State, keys []string, lastRun bool) error {
+	return Modify(func(cur *State) bool {
+		for _, key := range keys {
+			if entry, ok := st.Packages[key]; ok {
+				cur.Packages[key] = entry
+			} else {
+				delete(cur.Packages, key)
+			}
+		}
+		if lastRun {
+			cur.LastRunDay = st.LastRunDay
+		}
+		return true
+	})
 }
 
 func Update(st *State, key string, updateAvailable bool, remoteVersion string, now time.Time) (Entry, bool) {
@@ -165,3 +221,40 @@ func ShouldRunNow(st State, now time.Time, hour, minute int) bool {
 func MarkRun(st *State, now time.Time) {
 	st.LastRunDay = now.Format("2006-01-02")
 }
+
+func HoldFor(st State, key string) (Hold, bool) {
+	h, ok := st.Holds[key]
+	return h, ok
+}
+
+// SetHold grava o hold do pacote no auto-update.json.
+func SetHold(key, version string, now time.Time) error {
+	return Modify(func(st *State) bool {
+		if st.Holds == nil {
+			st.Holds = map[string]Hold{}
+		}
+		st.Holds[key] = Hold{Version: version, Since: now.Unix()}
+		return true
+	})
+}
+
+// ClearHold remove o hold; devolve false se o pacote nao estava em hold.
+func ClearHold(key string) (bool, error) {
+	removed := false
+	err := Modify(func(st *State) bool {
+		if _, removed = st.Holds[key]; removed {
+			delete(st.Holds, key)
+		}
+		return removed
+	})
+	return removed, err
+}
+
+// Forget remove o pacote do auto-update.json (ex.: apos uninstall).
+func Forget(key string) error {
+	return Modify(func(st *State) bool {
+		_, held := st.Holds[key]
+		delete(st.Holds, key)
+		return Clear(st, key) || held
+	})
+}
@@ -0,0 +1,52 @@
+package autoupdate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zid-packages/internal/lock"
+)
+
+func TestSavePackages_KeepsHoldsMadeDuringRun(t *testing.T) {
+	origPath, origLockDir := StatePath, lock.Dir
+	StatePath = filepath.Join(t.TempDir(), "auto-update.json")
+	lock.Dir = t.TempDir()
+	t.Cleanup(func() { StatePath, lock.Dir = origPath, origLockDir })
+
+	now := time.Unix(1700000000, 0)
+	if err := SetHold("zid-logs", "0.3.0", now); err != nil {
+		t.Fatal(err)
+	}
+	// Copia em memoria da rodada, carregada antes do hold/unhold.
+	run, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHold("zid-proxy", "1.4.1", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ClearHold("zid-logs"); err != nil {
+		t.Fatal(err)
+	}
+
+	Update(&run, "zid-proxy", true, "1.4.2", now)
+	MarkRun(&run, now)
+	if err := SavePackages(run, []string{"zid-proxy"}, true); err != nil {
+		t.Fatalf("SavePackages() err=%v", err)
+	}
+
+	st, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold, ok := HoldFor(st, "zid-proxy"); !ok || hold.Version != "1.4.1" {
+		t.Fatalf("HoldFor(zid-proxy)=%#v,%v; hold made during the run was lost", hold, ok)
+	}
+	if _, ok := HoldFor(st, "zid-logs"); ok {
+		t.Fatalf("HoldFor(zid-logs) came back after unhold")
+	}
+	if st.Packages["zid-proxy"].Version != "1.4.2" || st.LastRunDay != run.LastRunDay {
+		t.Fatalf("SavePackages() state=%#v; want the run's entries", st)
+	}
+}
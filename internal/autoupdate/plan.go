@@ -0,0 +1,71 @@
+package autoupdate
+
+import (
+	"time"
+
+	"zid-packages/internal/lock"
+	"zid-packages/internal/packages"
+)
+
+// Plan e o resultado de "auto-update --once --dry-run": o que RunOnce faria
+// agora, sem baixar bundles, rodar scripts nem gravar o auto-update.json.
+type Plan struct {
+	Now        int64               `json:"now"`
+	LastRunDay string              `json:"last_run_day,omitempty"`
+	NextRunAt  int64               `json:"next_run_at"`
+	Blocked    string              `json:"blocked,omitempty"`
+	Steps      []packages.PlanStep `json:"steps"`
+}
+
+func PlanOnce(now time.Time) (Plan, error) {
+	st, err := Load()
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{
+		Now:        now.Unix(),
+		LastRunDay: st.LastRunDay,
+		NextRunAt:  NextRunAt(st, now).Unix(),
+		Steps:      []packages.PlanStep{},
+	}
+	if info, held := lock.Held(lock.Global); held {
+		plan.Blocked = info.Op
+	}
+	// evaluate altera o estado como a rodada real; trabalha numa copia.
+	st = cloneState(st)
+	for _, pkg := range updateOrder(nil) {
+		step, _, _ := evaluate(&st, pkg, now)
+		if step.Action == packages.PlanUpdate {
+			step, err = packages.PlanUpdateStep(pkg.Key, false)
+			if err != nil {
+				return plan, err
+			}
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// NextRunAt devolve a proxima janela diaria (ScheduleHour:ScheduleMinute) em
+// que o daemon dispara o auto-update.
+func NextRunAt(st State, now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), ScheduleHour, ScheduleMinute, 0, 0, now.Location())
+	if !now.Before(next.Add(time.Minute)) || st.LastRunDay == now.Format("2006-01-02") {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func cloneState(st State) State {
+	out := State{LastRunDay: st.LastRunDay, Packages: map[string]Entry{}}
+	for k, v := range st.Packages {
+		out.Packages[k] = v
+	}
+	if st.Holds != nil {
+		out.Holds = map[string]Hold{}
+		for k, v := range st.Holds {
+			out.Holds[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package autoupdate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRunAt(t *testing.T) {
+	loc := time.UTC
+	window := time.Date(2026, 3, 10, ScheduleHour, ScheduleMinute, 0, 0, loc)
+	tomorrow := window.AddDate(0, 0, 1)
+	tests := []struct {
+		name string
+		now  time.Time
+		last string
+		want time.Time
+	}{
+		{name: "antes da janela", now: time.Date(2026, 3, 10, 12, 0, 0, 0, loc), want: window},
+		{name: "dentro da janela", now: window.Add(30 * time.Second), want: window},
+		{name: "ja rodou hoje", now: window.Add(30 * time.Second), last: "2026-03-10", want: tomorrow},
+		{name: "depois da janela", now: time.Date(2026, 3, 11, 0, 30, 0, 0, loc), want: tomorrow},
+	}
+	for _, tc := range tests {
+		got := NextRunAt(State{LastRunDay: tc.last}, tc.now)
+		if !got.Equal(tc.want) {
+			t.Fatalf("%s: NextRunAt()=%v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCloneStateIsIndependent(t *testing.T) {
+	st := State{Packages: map[string]Entry{"zid-proxy": {Version: "1.0.0", FirstSeen: 1}}}
+	cp := cloneState(st)
+	Update(&cp, "zid-proxy", false, "", time.Unix(10, 0))
+	if _, ok := st.Packages["zid-proxy"]; !ok {
+		t.Fatalf("cloneState() shares the packages map")
+	}
+}
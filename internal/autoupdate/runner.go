@@ -3,65 +3,102 @@ package autoupdate
 import (
 	"time"
 
+	"zid-packages/internal/lock"
 	"zid-packages/internal/logx"
 	"zid-packages/internal/packages"
 )
 
+// globalLockWait cobre uma rodada do watchdog em andamento; sem isso a
+// janela de ScheduleMinute passaria e o auto-update so rodaria no dia seguinte.
+const globalLockWait = 5 * time.Minute
+
 func RunOnce(logger *logx.Logger, now time.Time) {
-	st, _ := Load()
-	changed := false
-	// Atualiza o próprio zid-packages por último. Mesmo com update seguro, isso
-	// reduz o risco de interromper a rodada quando algum ambiente ainda reinicia
-	// o daemon durante a instalação.
-	all := packages.All()
-	ordered := make([]packages.Package, 0, len(all))
-	var self *packages.Package
-	for _, pkg := range all {
-		if pkg.Key == "zid-packages" {
-			p := pkg
-			self = &p
-			continue
-		}
-		ordered = append(ordered, pkg)
-	}
-	if self != nil {
-		ordered = append(ordered, *self)
+	l, err := lock.AcquireWait(lock.Global, "auto-update", globalLockWait)
+	if err != nil {
+		logger.Error("auto-update ignorado: " + err.Error())
+		return
 	}
+	defer l.Release()
 
-	for _, pkg := range ordered {
-		if !packages.Installed(pkg.Key) {
-			if Clear(&st, pkg.Key) {
-				changed = true
-			}
-			continue
-		}
-		remoteVersion := packages.VersionRemote(pkg.Key)
-		localVersion := packages.VersionLocal(pkg.Key)
-		updateAvailable := packages.UpdateAvailableWith(localVersion, remoteVersion)
-		entry, updated := Update(&st, pkg.Key, updateAvailable, remoteVersion, now)
-		if updated {
-			changed = true
-		}
-		if !updateAvailable {
-			continue
+	st, _ := Load()
+	keys := []string{}
+	for _, pkg := range updateOrder(logger) {
+		// Um update pode levar minutos: hold/unhold feitos durante a rodada
+		// valem para os pacotes seguintes.
+		if cur, err := Load(); err == nil {
+			st.Holds = cur.Holds
 		}
-		if !Due(entry, now) {
+		keys = append(keys, pkg.Key)
+		step, _, logSkip := evaluate(&st, pkg, now)
+		if step.Action != packages.PlanUpdate {
+			if logSkip {
+				logger.Info("auto-update ignorado: " + pkg.Key + " " + step.Reason)
+			}
 			continue
 		}
 		logger.Info("auto-update start: " + pkg.Key)
-		if err := packages.Update(logger, pkg.Key); err != nil {
+		if err := packages.Update(logger, pkg.Key, false); err != nil {
 			logger.Error("auto-update failed: " + pkg.Key + " err=" + err.Error())
 			continue
 		}
-		if Clear(&st, pkg.Key) {
-			changed = true
-		}
+		Clear(&st, pkg.Key)
 		logger.Info("auto-update done: " + pkg.Key)
 	}
 	MarkRun(&st, now)
-	if changed {
-		_ = Save(st)
-	} else {
-		_ = Save(st)
+	if err := SavePackages(st, keys, true); err != nil {
+		logger.Error("auto-update: falha ao gravar estado: " + err.Error())
+	}
+}
+
+// updateOrder coloca dependencias antes de quem depende delas e o proprio
+// zid-packages por ultimo, para nao interromper a rodada se o daemon
+// reiniciar no update.
+func updateOrder(logger *logx.Logger) []packages.Package {
+	all := packages.All()
+	ordered, err := packages.UpdateOrder(all)
+	if err != nil {
+		if logger != nil {
+			logger.Error("auto-update: ordem de dependencias invalida: " + err.Error())
+		}
+		return all
+	}
+	return ordered
+}
+
+// evaluate decide o que a rodada faz com o pacote, atualizando st como a
+// rodada real (FirstSeen, limpeza de desinstalados). logSkip marca os
+// pacotes vencidos que ficam de fora e por isso vao para o log.
+func evaluate(st *State, pkg packages.Package, now time.Time) (step packages.PlanStep, changed, logSkip bool) {
+	step = packages.PlanStep{Package: pkg.Key, Action: packages.PlanSkip}
+	if !packages.Installed(pkg.Key) {
+		step.Reason = "nao instalado"
+		return step, Clear(st, pkg.Key), false
+	}
+	remoteVersion := packages.VersionRemote(pkg.Key)
+	localVersion := packages.VersionLocal(pkg.Key)
+	step.From, step.To = localVersion, remoteVersion
+	updateAvailable := packages.UpdateAvailableWith(localVersion, remoteVersion)
+	entry, changed := Update(st, pkg.Key, updateAvailable, remoteVersion, now)
+	if !updateAvailable {
+		step.Reason = "sem atualizacao"
+		return step, changed, false
+	}
+	if !Due(entry, now) {
+		step.Reason = "aguardando prazo (due_at=" + DueAt(entry, ThresholdDays(), now.Location()).Format(time.RFC3339) + ")"
+		return step, changed, false
+	}
+	if hold, ok := HoldFor(*st, pkg.Key); ok {
+		step.Reason = "em hold (pinned=" + hold.Version + ")"
+		return step, changed, true
+	}
+	if failed, ok := packages.FailedUpdateFor(pkg.Key); ok && failed.Version == remoteVersion {
+		step.Reason = remoteVersion + " falhou no health check"
+		return step, changed, true
+	}
+	if !packages.SignedBundle(pkg) {
+		step.Reason = "sem bundle assinado"
+		return step, changed, true
 	}
+	step.Action = packages.PlanUpdate
+	return step, changed, false
 }
@@ -0,0 +1,269 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"zid-packages/internal/s3"
+	"zid-packages/internal/secure"
+)
+
+const (
+	URL     = "https://s3.soulsolucoes.com.br/soul/portal/catalog.json"
+	MaxAge  = 15 * time.Minute
+	maxSize = 1024 * 1024
+	// Evita repetir o download a cada consulta quando o S3 esta inacessivel.
+	retryAfter = 1 * time.Minute
+)
+
+type Entry struct {
+	Key            string `json:"key"`
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	BundleURL      string `json:"bundle_url"`
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	NotesURL       string `json:"release_notes_url,omitempty"`
+	MinZidPackages string `json:"min_zid_packages,omitempty"`
+	// Channels publica releases fora do canal stable (ex.: "beta", "lts"); a
+	// entrada principal e sempre a do stable.
+	Channels map[string]Entry `json:"channels,omitempty"`
+}
+
+const ChannelStable = "stable"
+
+type Catalog struct {
+	GeneratedAt int64     `json:"generated_at"`
+	Packages    []Entry   `json:"packages"`
+	FetchedAt   time.Time `json:"-"`
+}
+
+var CachePath = "/var/db/zid-packages/catalog.json"
+
+// ErrStale: o catalogo baixado e mais antigo que o ja conhecido (replay de um
+// catalogo assinado antigo).
+var ErrStale = errors.New("catalog: generated_at mais antigo que o catalogo em cache")
+
+var (
+	verify     = secure.VerifyRelease
+	fetchBytes = s3.FetchBytes
+)
+
+var (
+	mu          sync.Mutex
+	current     *Catalog
+	lastFailure time.Time
+	readOnly    bool
+)
+
+func (c Catalog) Lookup(key string) (Entry, bool) {
+	for _, entry := range c.Packages {
+		if entry.Key == key {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// LookupChannel devolve a release do pacote no canal pedido. Canal vazio ou
+// stable e a entrada principal.
+func (c Catalog) LookupChannel(key, channel string) (Entry, bool) {
+	entry, ok := c.Lookup(key)
+	if !ok || channel == "" || channel == ChannelStable {
+		return entry, ok
+	}
+	release, ok := entry.Channels[channel]
+	if !ok || release.Version == "" {
+		return Entry{}, false
+	}
+	release.Key = entry.Key
+	if release.Name == "" {
+		release.Name = entry.Name
+	}
+	release.Channels = nil
+	return release, true
+}
+
+func Parse(data, sig []byte) (Catalog, error) {
+	if err := verify(data, sig); err != nil {
+		return Catalog{}, fmt.Errorf("catalog: %w", err)
+	}
+	var cat Catalog
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return Catalog{}, err
+	}
+	seen := map[string]bool{}
+	for i, entry := range cat.Packages {
+		key := strings.TrimSpace(entry.Key)
+		if key == "" {
+			return Catalog{}, errors.New("catalog: entrada sem key")
+		}
+		if seen[key] {
+			return Catalog{}, fmt.Errorf("catalog: key duplicada: %s", key)
+		}
+		seen[key] = true
+		cat.Packages[i].Key = key
+		cat.Packages[i].Version = strings.TrimSpace(entry.Version)
+		cat.Packages[i].SHA256 = strings.ToLower(strings.TrimSpace(entry.SHA256))
+		for name, release := range entry.Channels {
+			release.Version = strings.TrimSpace(release.Version)
+			release.SHA256 = strings.ToLower(strings.TrimSpace(release.SHA256))
+			entry.Channels[name] = release
+		}
+	}
+	return cat, nil
+}
+
+func LoadCached() (Catalog, error) {
+	data, err := os.ReadFile(CachePath)
+	if err != nil {
+		return Catalog{}, err
+	}
+	sig, err := os.ReadFile(CachePath + ".sig")
+	if err != nil {
+		return Catalog{}, err
+	}
+	cat, err := Parse(data, sig)
+	if err != nil {
+		return Catalog{}, err
+	}
+	if info, err := os.Stat(CachePath); err == nil {
+		cat.FetchedAt = info.ModTime()
+	}
+	return cat, nil
+}
+
+// SetReadOnly faz Current nunca gravar o cache em disco: o catalogo remoto
+// ainda e baixado e verificado, mas fica so em memoria. Usado pelo dry-run.
+func SetReadOnly(on bool) {
+	mu.Lock()
+	readOnly = on
+	mu.Unlock()
+}
+
+func Refresh() (Catalog, error) {
+	cat, data, sig, err := fetch()
+	if err != nil {
+		return Catalog{}, err
+	}
+	if err := save(data, sig); err != nil {
+		return Catalog{}, err
+	}
+	mu.Lock()
+	current = &cat
+	mu.Unlock()
+	return cat, nil
+}
+
+// fetch baixa e valida o catalogo remoto sem gravar nada.
+func fetch() (Catalog, []byte, []byte, error) {
+	data, err := fetchBytes(URL, maxSize)
+	if err != nil {
+		return Catalog{}, nil, nil, err
+	}
+	sig, err := fetchBytes(URL+".sig", 4096)
+	if err != nil {
+		return Catalog{}, nil, nil, err
+	}
+	cat, err := Parse(data, sig)
+	if err != nil {
+		return Catalog{}, nil, nil, err
+	}
+	if known := newestKnown(); cat.GeneratedAt < known {
+		return Catalog{}, nil, nil, fmt.Errorf("%w (%d < %d)", ErrStale, cat.GeneratedAt, known)
+	}
+	cat.FetchedAt = time.Now()
+	return cat, data, sig, nil
+}
+
+// refreshReadOnly e o Refresh do modo SetReadOnly: so atualiza a memoria.
+func refreshReadOnly() (Catalog, error) {
+	cat, _, _, err := fetch()
+	if err != nil {
+		return Catalog{}, err
+	}
+	mu.Lock()
+	current = &cat
+	mu.Unlock()
+	return cat, nil
+}
+
+// Current devolve o catalogo em cache, baixando novamente quando passou de
+// MaxAge. Se o download falhar, o cache antigo continua valendo.
+func Current() (Catalog, error) {
+	mu.Lock()
+	if current != nil && time.Since(current.FetchedAt) < MaxAge {
+		cat := *current
+		mu.Unlock()
+		return cat, nil
+	}
+	failedRecently := !lastFailure.IsZero() && time.Since(lastFailure) < retryAfter
+	refresh := Refresh
+	if readOnly {
+		refresh = refreshReadOnly
+	}
+	mu.Unlock()
+
+	cached, cacheErr := LoadCached()
+	if cacheErr == nil && time.Since(cached.FetchedAt) < MaxAge {
+		mu.Lock()
+		current = &cached
+		mu.Unlock()
+		return cached, nil
+	}
+	if !failedRecently {
+		cat, err := refresh()
+		if err == nil {
+			return cat, nil
+		}
+		mu.Lock()
+		lastFailure = time.Now()
+		mu.Unlock()
+		if cacheErr != nil {
+			return Catalog{}, err
+		}
+	}
+	if cacheErr != nil {
+		return Catalog{}, cacheErr
+	}
+	return cached, nil
+}
+
+// newestKnown e o maior generated_at entre o catalogo em memoria e o do
+// disco; um catalogo baixado nunca pode voltar para antes dele.
+func newestKnown() int64 {
+	var known int64
+	mu.Lock()
+	if current != nil {
+		known = current.GeneratedAt
+	}
+	mu.Unlock()
+	if cached, err := LoadCached(); err == nil && cached.GeneratedAt > known {
+		known = cached.GeneratedAt
+	}
+	return known
+}
+
+func save(data, sig []byte) error {
+	if err := os.MkdirAll(filepath.Dir(CachePath), 0700); err != nil {
+		return err
+	}
+	if err := writeAtomic(CachePath+".sig", sig); err != nil {
+		return err
+	}
+	return writeAtomic(CachePath, data)
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,172 @@
+package catalog
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := verify
+	verify = func(payload, sig []byte) error {
+		raw, err := base64.StdEncoding.DecodeString(string(sig))
+		if err != nil || !ed25519.Verify(pub, payload, raw) {
+			return errors.New("assinatura invalida")
+		}
+		return nil
+	}
+	t.Cleanup(func() { verify = orig })
+	return priv
+}
+
+func sign(priv ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)))
+}
+
+func TestParse_VerifiesAndNormalizes(t *testing.T) {
+	priv := withTestKey(t)
+	data := []byte(`{"generated_at":1,"packages":[{"key":" zid-proxy ","version":"1.2.3 ","sha256":"ABCD"},{"key":"zid-new","version":"0.1.0"}]}`)
+	cat, err := Parse(data, sign(priv, data))
+	if err != nil {
+		t.Fatalf("Parse() err=%v", err)
+	}
+	entry, ok := cat.Lookup("zid-proxy")
+	if !ok || entry.Version != "1.2.3" || entry.SHA256 != "abcd" {
+		t.Fatalf("Lookup(zid-proxy)=%#v,%v", entry, ok)
+	}
+	if _, ok := cat.Lookup("zid-new"); !ok {
+		t.Fatalf("Lookup(zid-new) should find catalog-only package")
+	}
+}
+
+func TestParse_RejectsBadSignature(t *testing.T) {
+	priv := withTestKey(t)
+	data := []byte(`{"packages":[{"key":"zid-proxy","version":"1.2.3"}]}`)
+	sig := sign(priv, []byte(`{"packages":[]}`))
+	if _, err := Parse(data, sig); err == nil {
+		t.Fatalf("Parse() should reject signature of other payload")
+	}
+}
+
+func TestParse_RejectsDuplicateKeys(t *testing.T) {
+	priv := withTestKey(t)
+	data := []byte(`{"packages":[{"key":"zid-proxy"},{"key":"zid-proxy"}]}`)
+	if _, err := Parse(data, sign(priv, data)); err == nil {
+		t.Fatalf("Parse() should reject duplicated keys")
+	}
+}
+
+func TestLookupChannel(t *testing.T) {
+	priv := withTestKey(t)
+	data := []byte(`{"packages":[{"key":"zid-proxy","name":"ZID Proxy","version":"1.2.3","channels":{"beta":{"version":"1.3.0-beta1 ","bundle_url":"https://example/zid-proxy-beta.tar.gz","sha256":"EF01"}}}]}`)
+	cat, err := Parse(data, sign(priv, data))
+	if err != nil {
+		t.Fatalf("Parse() err=%v", err)
+	}
+	cases := []struct {
+		channel string
+		want    string
+		ok      bool
+	}{
+		{channel: "", want: "1.2.3", ok: true},
+		{channel: "stable", want: "1.2.3", ok: true},
+		{channel: "beta", want: "1.3.0-beta1", ok: true},
+		{channel: "lts", ok: false},
+	}
+	for _, tc := range cases {
+		entry, ok := cat.LookupChannel("zid-proxy", tc.channel)
+		if ok != tc.ok || entry.Version != tc.want {
+			t.Fatalf("LookupChannel(%q)=%q,%v; want %q,%v", tc.channel, entry.Version, ok, tc.want, tc.ok)
+		}
+	}
+	beta, _ := cat.LookupChannel("zid-proxy", "beta")
+	if beta.Key != "zid-proxy" || beta.Name != "ZID Proxy" || beta.SHA256 != "ef01" {
+		t.Fatalf("LookupChannel(beta)=%#v", beta)
+	}
+}
+
+func TestRefresh_RejectsOlderCatalog(t *testing.T) {
+	priv := withTestKey(t)
+	origPath, origFetch := CachePath, fetchBytes
+	CachePath = filepath.Join(t.TempDir(), "catalog.json")
+	t.Cleanup(func() {
+		CachePath, fetchBytes = origPath, origFetch
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+	})
+	serve := func(data []byte) {
+		fetchBytes = func(url string, max int64) ([]byte, error) {
+			if strings.HasSuffix(url, ".sig") {
+				return sign(priv, data), nil
+			}
+			return data, nil
+		}
+	}
+
+	newer := []byte(`{"generated_at":200,"packages":[{"key":"zid-proxy","version":"1.2.3"}]}`)
+	serve(newer)
+	if _, err := Refresh(); err != nil {
+		t.Fatalf("Refresh() err=%v", err)
+	}
+	mu.Lock()
+	current = nil
+	mu.Unlock()
+
+	serve([]byte(`{"generated_at":100,"packages":[{"key":"zid-proxy","version":"1.2.0"}]}`))
+	if _, err := Refresh(); !errors.Is(err, ErrStale) {
+		t.Fatalf("Refresh() err=%v; want ErrStale", err)
+	}
+	cached, err := LoadCached()
+	if err != nil || cached.GeneratedAt != 200 {
+		t.Fatalf("LoadCached()=%d,%v; want cache untouched", cached.GeneratedAt, err)
+	}
+
+	serve([]byte(`{"generated_at":200,"packages":[{"key":"zid-proxy","version":"1.2.3"}]}`))
+	if _, err := Refresh(); err != nil {
+		t.Fatalf("Refresh() same generated_at err=%v", err)
+	}
+}
+
+func TestCurrent_ReadOnlyDoesNotSave(t *testing.T) {
+	priv := withTestKey(t)
+	origPath, origFetch := CachePath, fetchBytes
+	CachePath = filepath.Join(t.TempDir(), "catalog.json")
+	SetReadOnly(true)
+	t.Cleanup(func() {
+		CachePath, fetchBytes = origPath, origFetch
+		SetReadOnly(false)
+		mu.Lock()
+		current = nil
+		lastFailure = time.Time{}
+		mu.Unlock()
+	})
+	data := []byte(`{"generated_at":100,"packages":[{"key":"zid-proxy","version":"1.2.3"}]}`)
+	fetchBytes = func(url string, max int64) ([]byte, error) {
+		if strings.HasSuffix(url, ".sig") {
+			return sign(priv, data), nil
+		}
+		return data, nil
+	}
+
+	cat, err := Current()
+	if err != nil {
+		t.Fatalf("Current() err=%v", err)
+	}
+	if entry, ok := cat.Lookup("zid-proxy"); !ok || entry.Version != "1.2.3" {
+		t.Fatalf("Lookup(zid-proxy)=%#v,%v", entry, ok)
+	}
+	if _, err := os.Stat(CachePath); !os.IsNotExist(err) {
+		t.Fatalf("Current() em modo somente leitura gravou o cache: %v", err)
+	}
+}
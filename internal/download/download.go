@@ -0,0 +1,393 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	KindNetwork  = "network"
+	KindTimeout  = "timeout"
+	KindHTTP     = "http_status"
+	KindTooLarge = "too_large"
+	KindIO       = "io"
+)
+
+// Error e o erro devolvido por File/Bytes. Kind permite ao CLI/GUI tratar a
+// falha sem depender do texto.
+type Error struct {
+	Kind   string
+	URL    string
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	switch e.Kind {
+	case KindHTTP:
+		if e.Err != nil {
+			return fmt.Sprintf("download %s: http status %d: %v", e.URL, e.Status, e.Err)
+		}
+		return fmt.Sprintf("download %s: http status %d", e.URL, e.Status)
+	case KindTooLarge:
+		return fmt.Sprintf("download %s: excede tamanho maximo", e.URL)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("download %s: %s: %v", e.URL, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("download %s: %s", e.URL, e.Kind)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type Options struct {
+	ConnectTimeout time.Duration
+	// ReadTimeout limita o tempo sem receber dados (cabecalhos ou corpo).
+	ReadTimeout time.Duration
+	Retries     int
+	Backoff     time.Duration
+	MaxSize     int64
+	Progress    func(done, total int64)
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ConnectTimeout: 10 * time.Second,
+		ReadTimeout:    30 * time.Second,
+		Retries:        3,
+		Backoff:        2 * time.Second,
+		MaxSize:        512 * 1024 * 1024,
+	}
+}
+
+func (o Options) withDefaults() Options {
+	def := DefaultOptions()
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = def.ConnectTimeout
+	}
+	if o.ReadTimeout <= 0 {
+		o.ReadTimeout = def.ReadTimeout
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = def.Backoff
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = def.MaxSize
+	}
+	return o
+}
+
+// idleConnTimeout limita conexoes keep-alive esquecidas; File/Bytes/Stat
+// tambem fecham as ociosas ao terminar, ja que cada chamada tem o seu client.
+const idleConnTimeout = 30 * time.Second
+
+func (o Options) client() *http.Client {
+	dialer := &net.Dialer{Timeout: o.ConnectTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   o.ConnectTimeout,
+			ResponseHeaderTimeout: o.ReadTimeout,
+			IdleConnTimeout:       idleConnTimeout,
+		},
+	}
+}
+
+// errRangeMismatch: o 206 comecou em outro offset que o tamanho do .part.
+var errRangeMismatch = errors.New("content-range nao confere com o download parcial")
+
+// File baixa url para dest. O conteudo parcial fica em dest+".part" e e
+// retomado via Range nas tentativas seguintes (inclusive em outra execucao).
+func File(url, dest string, opts Options) error {
+	if strings.TrimSpace(url) == "" {
+		return errors.New("url vazio")
+	}
+	opts = opts.withDefaults()
+	client := opts.client()
+	defer client.CloseIdleConnections()
+	part := dest + ".part"
+	err := withRetries(opts, func() error {
+		return fetchToFile(opts, client, url, part)
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return &Error{Kind: KindIO, URL: url, Err: err}
+	}
+	return nil
+}
+
+// Bytes baixa url inteiro para memoria respeitando opts.MaxSize.
+func Bytes(url string, opts Options) ([]byte, error) {
+	if strings.TrimSpace(url) == "" {
+		return nil, errors.New("url vazio")
+	}
+	opts = opts.withDefaults()
+	client := opts.client()
+	defer client.CloseIdleConnections()
+	var out []byte
+	err := withRetries(opts, func() error {
+		var buf bytes.Buffer
+		if err := fetch(opts, client, url, 0, &buf); err != nil {
+			return err
+		}
+		out = buf.Bytes()
+		return nil
+	})
+	return out, err
+}
+
+// Stat confere que url esta disponivel (HEAD) sem baixar o conteudo e
+// devolve o tamanho anunciado, ou -1 quando o servidor nao informa.
+func Stat(url string, opts Options) (int64, error) {
+	if strings.TrimSpace(url) == "" {
+		return 0, errors.New("url vazio")
+	}
+	opts = opts.withDefaults()
+	client := opts.client()
+	defer client.CloseIdleConnections()
+	size := int64(-1)
+	err := withRetries(opts, func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		idle := newIdleTimer(opts.ReadTimeout, cancel)
+		defer idle.stop()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return &Error{Kind: KindNetwork, URL: url, Err: err}
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return classify(url, err, idle)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &Error{Kind: KindHTTP, URL: url, Status: resp.StatusCode}
+		}
+		if resp.ContentLength > opts.MaxSize {
+			return &Error{Kind: KindTooLarge, URL: url}
+		}
+		size = resp.ContentLength
+		return nil
+	})
+	return size, err
+}
+
+func withRetries(opts Options, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(opts.Backoff * time.Duration(1<<(attempt-1)))
+		}
+		err = fn()
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func retryable(err error) bool {
+	var derr *Error
+	if !errors.As(err, &derr) {
+		return false
+	}
+	switch derr.Kind {
+	case KindNetwork, KindTimeout:
+		return true
+	case KindHTTP:
+		return derr.Status >= 500 || derr.Status == http.StatusTooManyRequests || derr.Status == http.StatusRequestTimeout
+	default:
+		return false
+	}
+}
+
+func fetchToFile(opts Options, client *http.Client, url, part string) error {
+	offset := int64(0)
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return &Error{Kind: KindIO, URL: url, Err: err}
+	}
+	defer f.Close()
+	w := &offsetWriter{f: f, offset: offset}
+	err = fetch(opts, client, url, offset, w)
+	var derr *Error
+	if errors.Is(err, errRangeMismatch) ||
+		(errors.As(err, &derr) && derr.Kind == KindHTTP && derr.Status == http.StatusRequestedRangeNotSatisfiable) {
+		// .part invalido para o arquivo atual (ex.: bundle republicado) ou
+		// servidor respondeu outro trecho: recomeca do zero.
+		if err := w.reset(); err != nil {
+			return &Error{Kind: KindIO, URL: url, Err: err}
+		}
+		err = fetch(opts, client, url, 0, w)
+	}
+	return err
+}
+
+// offsetWriter escreve a partir de offset; reset() volta ao inicio quando o
+// servidor ignora o Range e manda o arquivo inteiro.
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+func (w *offsetWriter) reset() error {
+	w.offset = 0
+	return w.f.Truncate(0)
+}
+
+func fetch(opts Options, client *http.Client, url string, offset int64, w io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idle := newIdleTimer(opts.ReadTimeout, cancel)
+	defer idle.stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &Error{Kind: KindNetwork, URL: url, Err: err}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return classify(url, err, idle)
+	}
+	defer resp.Body.Close()
+
+	done := int64(0)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return &Error{Kind: KindHTTP, URL: url, Status: resp.StatusCode, Err: errRangeMismatch}
+		}
+		done = offset
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if rw, ok := w.(*offsetWriter); ok && offset > 0 {
+			if err := rw.reset(); err != nil {
+				return &Error{Kind: KindIO, URL: url, Err: err}
+			}
+		}
+	default:
+		return &Error{Kind: KindHTTP, URL: url, Status: resp.StatusCode}
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = done + resp.ContentLength
+		if total > opts.MaxSize {
+			return &Error{Kind: KindTooLarge, URL: url}
+		}
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		idle.reset()
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			done += int64(n)
+			if done > opts.MaxSize {
+				return &Error{Kind: KindTooLarge, URL: url}
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return &Error{Kind: KindIO, URL: url, Err: err}
+			}
+			if opts.Progress != nil {
+				opts.Progress(done, total)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return classify(url, rerr, idle)
+		}
+	}
+	if total >= 0 && done != total {
+		return &Error{Kind: KindNetwork, URL: url, Err: io.ErrUnexpectedEOF}
+	}
+	return nil
+}
+
+// contentRangeStart le o inicio de "bytes <inicio>-<fim>/<total>".
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func classify(url string, err error, idle *idleTimer) error {
+	var nerr net.Error
+	if idle.fired() || (errors.As(err, &nerr) && nerr.Timeout()) {
+		return &Error{Kind: KindTimeout, URL: url, Err: err}
+	}
+	return &Error{Kind: KindNetwork, URL: url, Err: err}
+}
+
+type idleTimer struct {
+	mu      sync.Mutex
+	d       time.Duration
+	t       *time.Timer
+	didFire bool
+}
+
+func newIdleTimer(d time.Duration, onExpire func()) *idleTimer {
+	it := &idleTimer{d: d}
+	it.t = time.AfterFunc(d, func() {
+		it.mu.Lock()
+		it.didFire = true
+		it.mu.Unlock()
+		onExpire()
+	})
+	return it
+}
+
+func (it *idleTimer) reset() {
+	it.t.Reset(it.d)
+}
+
+func (it *idleTimer) stop() {
+	it.t.Stop()
+}
+
+func (it *idleTimer) fired() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.didFire
+}
@@ -0,0 +1,239 @@
+package download
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var payload = []byte(strings.Repeat("zid-packages bundle ", 4096))
+
+func testOptions() Options {
+	return Options{Retries: 2, Backoff: time.Millisecond, ReadTimeout: time.Second}
+}
+
+func TestFile_ResumesPartialDownload(t *testing.T) {
+	var gotRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "bundle.tar.gz", time.Time{}, strings.NewReader(string(payload)))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(dest+".part", payload[:1000], 0600); err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	opts := testOptions()
+	opts.Progress = func(done, total int64) { last = done }
+	if err := File(srv.URL, dest, opts); err != nil {
+		t.Fatalf("File() err=%v", err)
+	}
+	if gotRange != "bytes=1000-" {
+		t.Fatalf("Range=%q; want bytes=1000-", gotRange)
+	}
+	got, _ := os.ReadFile(dest)
+	if string(got) != string(payload) {
+		t.Fatalf("resumed file differs from payload")
+	}
+	if last != int64(len(payload)) {
+		t.Fatalf("progress done=%d; want %d", last, len(payload))
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Fatalf(".part should be renamed")
+	}
+}
+
+func TestFile_RestartsWhenRangeIgnored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		_, _ = w.Write(payload)
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(dest+".part", []byte("stale partial content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(srv.URL, dest, testOptions()); err != nil {
+		t.Fatalf("File() err=%v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if string(got) != string(payload) {
+		t.Fatalf("file should be restarted from scratch")
+	}
+}
+
+func TestFile_RestartsOnContentRangeMismatch(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Range") != "" {
+			// Ignora o offset pedido e manda outro trecho.
+			body := payload[10:]
+			w.Header().Set("Content-Range", "bytes 10-"+strconv.Itoa(len(payload)-1)+"/"+strconv.Itoa(len(payload)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(body)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		_, _ = w.Write(payload)
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(dest+".part", payload[:1000], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := File(srv.URL, dest, testOptions()); err != nil {
+		t.Fatalf("File() err=%v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if string(got) != string(payload) {
+		t.Fatalf("file should be restarted from scratch on Content-Range mismatch")
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("requests=%d; want 2", n)
+	}
+}
+
+func TestFile_ClosesIdleConnections(t *testing.T) {
+	var open int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt32(&open, 1)
+		case http.StateClosed, http.StateHijacked:
+			atomic.AddInt32(&open, -1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := File(srv.URL, filepath.Join(t.TempDir(), "bundle.tar.gz"), testOptions()); err != nil {
+			t.Fatalf("File() err=%v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&open) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("conexoes abertas apos File(): %d", atomic.LoadInt32(&open))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBytes_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("0.4.73\n"))
+	}))
+	defer srv.Close()
+
+	got, err := Bytes(srv.URL, testOptions())
+	if err != nil || string(got) != "0.4.73\n" {
+		t.Fatalf("Bytes()=%q,%v", got, err)
+	}
+	if calls != 3 {
+		t.Fatalf("calls=%d; want 3", calls)
+	}
+}
+
+func TestBytes_DoesNotRetryNotFound(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := Bytes(srv.URL, testOptions())
+	var derr *Error
+	if !errors.As(err, &derr) || derr.Kind != KindHTTP || derr.Status != http.StatusNotFound {
+		t.Fatalf("Bytes() err=%v; want http 404", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls=%d; want 1", calls)
+	}
+}
+
+func TestBytes_MaxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer srv.Close()
+
+	opts := testOptions()
+	opts.MaxSize = 100
+	_, err := Bytes(srv.URL, opts)
+	var derr *Error
+	if !errors.As(err, &derr) || derr.Kind != KindTooLarge {
+		t.Fatalf("Bytes() err=%v; want too_large", err)
+	}
+}
+
+func TestBytes_ReadTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	opts := testOptions()
+	opts.Retries = 0
+	opts.ReadTimeout = 100 * time.Millisecond
+	_, err := Bytes(srv.URL, opts)
+	var derr *Error
+	if !errors.As(err, &derr) || derr.Kind != KindTimeout {
+		t.Fatalf("Bytes() err=%v; want timeout", err)
+	}
+}
+
+func TestStat(t *testing.T) {
+	var method string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+	}))
+	defer srv.Close()
+
+	size, err := Stat(srv.URL+"/bundle.tar.gz", testOptions())
+	if err != nil {
+		t.Fatalf("Stat() err=%v", err)
+	}
+	if method != http.MethodHead {
+		t.Fatalf("method=%q; want HEAD", method)
+	}
+	if size != int64(len(payload)) {
+		t.Fatalf("Stat()=%d; want %d", size, len(payload))
+	}
+	_, err = Stat(srv.URL+"/missing", testOptions())
+	var derr *Error
+	if !errors.As(err, &derr) || derr.Status != http.StatusNotFound {
+		t.Fatalf("Stat(missing) err=%v; want http 404", err)
+	}
+}
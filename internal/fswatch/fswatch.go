@@ -0,0 +1,42 @@
+// Package fswatch avisa quando arquivos de configuracao mudam, com inotify no
+// Linux e kqueue no FreeBSD. O diretorio de cada arquivo tambem e observado:
+// o pfSense e o sysrc costumam gravar num temporario e renomear por cima.
+// Se o diretorio ainda nao existe (ex.: /usr/local/etc/rc.conf.d), o ancestral
+// existente mais proximo e observado ate ele aparecer.
+package fswatch
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsupported = errors.New("fswatch: sistema sem suporte")
+
+// Watcher entrega em Events o caminho (limpo) de cada arquivo adicionado com
+// Add que foi alterado, criado, removido ou substituido. Se o caminho e um
+// diretorio (rc.conf.d/<name>/), mudancas nos arquivos dentro dele tambem
+// geram evento com o caminho do diretorio. Um mesmo save pode gerar varios
+// eventos; quem consome faz o debounce.
+type Watcher interface {
+	Add(path string) error
+	Events() <-chan string
+	Close() error
+}
+
+// New cria o watcher nativo do sistema.
+func New() (Watcher, error) {
+	return newWatcher()
+}
+
+func cleanPath(path string) (string, error) {
+	return filepath.Abs(filepath.Clean(path))
+}
+
+// within indica se path fica abaixo de dir (e nao e o proprio dir).
+func within(path, dir string) bool {
+	if dir == string(filepath.Separator) {
+		return path != dir && strings.HasPrefix(path, dir)
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,198 @@
+package fswatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T) Watcher {
+	t.Helper()
+	w, err := New()
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("fswatch sem suporte neste sistema")
+	}
+	if err != nil {
+		t.Fatalf("New() err=%v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return w
+}
+
+func waitEvent(t *testing.T, w Watcher, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-w.Events():
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("nenhum evento para %s", want)
+		}
+	}
+}
+
+func drain(w Watcher) {
+	for {
+		select {
+		case <-w.Events():
+		case <-time.After(200 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "rc.conf.local")
+	other := filepath.Join(dir, "other.conf")
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"NO\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := filepath.Join(dir, "config.json")
+
+	w := newTestWatcher(t)
+	for _, path := range []string{conf, later} {
+		if err := w.Add(path); err != nil {
+			t.Fatalf("Add(%s) err=%v", path, err)
+		}
+	}
+
+	// Escrita no proprio arquivo.
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+	drain(w)
+
+	// Arquivo substituido por rename (write_config/sysrc).
+	tmp := filepath.Join(dir, "rc.conf.local.tmp")
+	if err := os.WriteFile(tmp, []byte("zid_proxy_enable=\"NO\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, conf); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+	drain(w)
+
+	// O arquivo substituido continua observado.
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+
+	// Arquivo que ainda nao existia no Add.
+	if err := os.WriteFile(later, []byte(`{"enabled": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, later)
+	drain(w)
+
+	// Arquivos fora da lista nao geram evento.
+	if err := os.WriteFile(other, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-w.Events():
+		t.Fatalf("evento inesperado: %s", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatcher_MissingDirs(t *testing.T) {
+	root := t.TempDir()
+	conf := filepath.Join(root, "usr", "local", "etc", "rc.conf.d", "zid_proxy")
+
+	w := newTestWatcher(t)
+	if err := w.Add(conf); err != nil {
+		t.Fatalf("Add(%s) err=%v", conf, err)
+	}
+
+	// Os diretorios aparecem depois do Add, um nivel por vez.
+	dir := filepath.Dir(conf)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+	drain(w)
+
+	// Depois de criado, o arquivo segue observado.
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"NO\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+	drain(w)
+
+	// Diretorio removido e recriado.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+	drain(w)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(conf, []byte("zid_proxy_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, conf)
+}
+
+func TestWatcher_DirectoryTarget(t *testing.T) {
+	root := t.TempDir()
+	confDir := filepath.Join(root, "rc.conf.d", "zid_proxy")
+	if err := os.MkdirAll(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	enable := filepath.Join(confDir, "enable")
+	if err := os.WriteFile(enable, []byte("zid_proxy_enable=\"NO\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := filepath.Join(root, "rc.conf.d", "zid_logs")
+
+	w := newTestWatcher(t)
+	for _, path := range []string{confDir, later} {
+		if err := w.Add(path); err != nil {
+			t.Fatalf("Add(%s) err=%v", path, err)
+		}
+	}
+
+	// Escrita num arquivo dentro do diretorio.
+	if err := os.WriteFile(enable, []byte("zid_proxy_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, confDir)
+	drain(w)
+
+	// Arquivo novo dentro do diretorio, e escrita nele depois.
+	extra := filepath.Join(confDir, "flags")
+	if err := os.WriteFile(extra, []byte("zid_proxy_flags=\"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, confDir)
+	drain(w)
+	if err := os.WriteFile(extra, []byte("zid_proxy_flags=\"-v\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, confDir)
+	drain(w)
+
+	// rc.conf.d/<name> criado como diretorio depois do Add.
+	if err := os.Mkdir(later, 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, later)
+	drain(w)
+	if err := os.WriteFile(filepath.Join(later, "enable"), []byte("zid_logs_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, later)
+}
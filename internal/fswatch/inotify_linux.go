@@ -0,0 +1,197 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_ATTRIB
+
+type inotifyWatcher struct {
+	file   *os.File
+	mu     sync.Mutex
+	dirs   map[int]string
+	wds    map[string]int
+	files  map[string]bool
+	events chan string
+	closed chan struct{}
+	done   chan struct{}
+}
+
+func newWatcher() (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotifyWatcher{
+		// Descritor nao bloqueante no poller do runtime: Close desbloqueia o Read.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   map[int]string{},
+		wds:    map[string]int{},
+		files:  map[string]bool{},
+		events: make(chan string, 64),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(path string) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.arm(path); err != nil {
+		return err
+	}
+	w.files[path] = true
+	return nil
+}
+
+// arm observa o diretorio de path ou, se ele ainda nao existe, o ancestral
+// existente mais proximo; a criacao do proximo nivel chama arm de novo. Se
+// path e um diretorio, ele tambem e observado.
+func (w *inotifyWatcher) arm(path string) error {
+	dir := filepath.Dir(path)
+	for {
+		err := w.watchDir(dir)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if (!errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.ENOTDIR)) || parent == dir {
+			return os.NewSyscallError("inotify_add_watch "+dir, err)
+		}
+		dir = parent
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if err := w.watchDir(path); err != nil {
+			return os.NewSyscallError("inotify_add_watch "+path, err)
+		}
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) watchDir(dir string) error {
+	if _, ok := w.wds[dir]; ok {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(int(w.file.Fd()), dir, inotifyMask|syscall.IN_ONLYDIR)
+	if err != nil {
+		return err
+	}
+	w.wds[dir] = wd
+	w.dirs[wd] = dir
+	return nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *inotifyWatcher) Close() error {
+	close(w.closed)
+	err := w.file.Close()
+	<-w.done
+	return err
+}
+
+func (w *inotifyWatcher) loop() {
+	defer close(w.done)
+	defer close(w.events)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return
+		}
+		for _, path := range w.parse(buf[:n]) {
+			select {
+			case w.events <- path:
+			case <-w.closed:
+				return
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) parse(buf []byte) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []string
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameLen := int(raw.Len)
+		start := offset + syscall.SizeofInotifyEvent
+		offset = start + nameLen
+		if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			// Fila do kernel estourou: qualquer arquivo pode ter mudado.
+			for path := range w.files {
+				out = append(out, path)
+			}
+			continue
+		}
+		dir, ok := w.dirs[int(raw.Wd)]
+		if !ok || offset > len(buf) {
+			continue
+		}
+		if raw.Mask&syscall.IN_IGNORED != 0 {
+			// Diretorio removido: volta a observar o ancestral existente.
+			delete(w.dirs, int(raw.Wd))
+			delete(w.wds, dir)
+			for path := range w.files {
+				if path == dir || within(path, dir) {
+					_ = w.arm(path)
+				}
+			}
+			continue
+		}
+		if nameLen == 0 {
+			continue
+		}
+		name := strings.TrimRight(string(buf[start:offset]), "\x00")
+		path := filepath.Join(dir, name)
+		created := raw.Mask&syscall.IN_ISDIR != 0 && raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0
+		if w.files[path] {
+			out = append(out, path)
+			if created {
+				_ = w.arm(path)
+			}
+		}
+		if w.files[dir] {
+			// Arquivo dentro de um diretorio observado (rc.conf.d/<name>/).
+			out = append(out, dir)
+		}
+		if created {
+			// Apareceu um diretorio no caminho de arquivos ainda sem
+			// diretorio: re-arma e avisa os que ja foram criados.
+			for file := range w.files {
+				if !within(file, path) {
+					continue
+				}
+				_ = w.arm(file)
+				if _, err := os.Lstat(file); err == nil {
+					out = append(out, file)
+				}
+			}
+		}
+	}
+	return out
+}
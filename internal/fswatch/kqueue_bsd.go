@@ -0,0 +1,304 @@
+//go:build freebsd || darwin
+
+package fswatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const (
+	kqueueFileFlags = syscall.NOTE_WRITE | syscall.NOTE_EXTEND | syscall.NOTE_ATTRIB |
+		syscall.NOTE_DELETE | syscall.NOTE_RENAME
+	kqueueDirFlags = syscall.NOTE_WRITE | syscall.NOTE_DELETE | syscall.NOTE_RENAME
+	// kevent sem timeout nao acorda com Close; o loop confere done a cada volta.
+	kqueuePoll = 500 * 1000 * 1000
+)
+
+// Tipos de descritor registrados: arquivo adicionado com Add, arquivo dentro
+// de um caminho adicionado que e diretorio, e diretorio pai observado.
+const (
+	identFile = iota
+	identEntry
+	identDir
+)
+
+// kqueue so avisa sobre descritores abertos: cada arquivo e aberto e
+// reaberto quando e substituido; o diretorio avisa quando o arquivo aparece.
+type kqueueWatcher struct {
+	kq      int
+	mu      sync.Mutex
+	files   map[string]*kqueueFile
+	entries map[string]*kqueueFile
+	dirs    map[string]int
+	idents  map[int]kqueueIdent
+	events  chan string
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+type kqueueFile struct {
+	fd    int
+	inode uint64
+}
+
+type kqueueIdent struct {
+	path string
+	kind int
+}
+
+func newWatcher() (Watcher, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(kq)
+	w := &kqueueWatcher{
+		kq:      kq,
+		files:   map[string]*kqueueFile{},
+		entries: map[string]*kqueueFile{},
+		dirs:    map[string]int{},
+		idents:  map[int]kqueueIdent{},
+		events:  make(chan string, 64),
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *kqueueWatcher) Add(path string) error {
+	path, err := cleanPath(path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.armDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if _, ok := w.files[path]; !ok {
+		f := &kqueueFile{fd: -1}
+		w.files[path] = f
+		w.reopen(path, f, identFile)
+		w.scanEntries(path)
+	}
+	return nil
+}
+
+// armDir observa dir ou, se ele ainda nao existe, o ancestral existente mais
+// proximo; a criacao do proximo nivel chama armDir de novo.
+func (w *kqueueWatcher) armDir(dir string) error {
+	for {
+		if _, ok := w.dirs[dir]; ok {
+			return nil
+		}
+		fd, err := w.register(dir, kqueueDirFlags, kqueueIdent{path: dir, kind: identDir})
+		if err == nil {
+			w.dirs[dir] = fd
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if (!errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.ENOTDIR)) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
+
+func (w *kqueueWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *kqueueWatcher) Close() error {
+	close(w.closed)
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for fd := range w.idents {
+		syscall.Close(fd)
+	}
+	return syscall.Close(w.kq)
+}
+
+func (w *kqueueWatcher) register(path string, fflags uint32, id kqueueIdent) (int, error) {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_ENABLE|syscall.EV_CLEAR)
+	ev.Fflags = fflags
+	if _, err := syscall.Kevent(w.kq, []syscall.Kevent_t{ev}, nil, nil); err != nil {
+		syscall.Close(fd)
+		return -1, os.NewSyscallError("kevent "+path, err)
+	}
+	w.idents[fd] = id
+	return fd, nil
+}
+
+// reopen passa a observar o arquivo atual em path; devolve true se ele e
+// outro arquivo (novo inode ou recem-criado).
+func (w *kqueueWatcher) reopen(path string, f *kqueueFile, kind int) bool {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		w.forget(f)
+		return false
+	}
+	inode := uint64(st.Ino)
+	if f.fd >= 0 && f.inode == inode {
+		return false
+	}
+	w.forget(f)
+	fd, err := w.register(path, kqueueFileFlags, kqueueIdent{path: path, kind: kind})
+	if err != nil {
+		return false
+	}
+	f.fd, f.inode = fd, inode
+	return true
+}
+
+func (w *kqueueWatcher) forget(f *kqueueFile) {
+	if f.fd < 0 {
+		return
+	}
+	delete(w.idents, f.fd)
+	syscall.Close(f.fd)
+	f.fd, f.inode = -1, 0
+}
+
+// scanEntries acompanha os arquivos dentro de target quando ele e um
+// diretorio: o kqueue do diretorio so avisa entradas criadas ou removidas,
+// nao escritas no conteudo delas.
+func (w *kqueueWatcher) scanEntries(target string) {
+	current := map[string]bool{}
+	if list, err := os.ReadDir(target); err == nil {
+		for _, e := range list {
+			if !e.IsDir() {
+				current[filepath.Join(target, e.Name())] = true
+			}
+		}
+	}
+	for path, f := range w.entries {
+		if filepath.Dir(path) == target && !current[path] {
+			w.forget(f)
+			delete(w.entries, path)
+		}
+	}
+	for path := range current {
+		f, ok := w.entries[path]
+		if !ok {
+			f = &kqueueFile{fd: -1}
+			w.entries[path] = f
+		}
+		w.reopen(path, f, identEntry)
+	}
+}
+
+func (w *kqueueWatcher) loop() {
+	defer close(w.done)
+	defer close(w.events)
+	buf := make([]syscall.Kevent_t, 32)
+	timeout := syscall.NsecToTimespec(kqueuePoll)
+	for {
+		select {
+		case <-w.closed:
+			return
+		default:
+		}
+		n, err := syscall.Kevent(w.kq, nil, buf, &timeout)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		for _, path := range w.handle(buf[:n]) {
+			select {
+			case w.events <- path:
+			case <-w.closed:
+				return
+			}
+		}
+	}
+}
+
+func (w *kqueueWatcher) handle(evs []syscall.Kevent_t) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []string
+	for _, ev := range evs {
+		id, ok := w.idents[int(ev.Ident)]
+		if !ok {
+			continue
+		}
+		gone := ev.Fflags&(syscall.NOTE_DELETE|syscall.NOTE_RENAME) != 0
+		switch id.kind {
+		case identFile:
+			f := w.files[id.path]
+			out = append(out, id.path)
+			if gone {
+				w.forget(f)
+				w.reopen(id.path, f, identFile)
+			}
+			w.scanEntries(id.path)
+		case identEntry:
+			out = append(out, filepath.Dir(id.path))
+			if gone {
+				f := w.entries[id.path]
+				w.forget(f)
+				w.reopen(id.path, f, identEntry)
+			}
+		case identDir:
+			if gone {
+				w.dirGone(id.path, int(ev.Ident))
+				continue
+			}
+			out = append(out, w.dirChanged(id.path)...)
+		}
+	}
+	return out
+}
+
+// dirChanged trata entrada criada/removida/renomeada em dir.
+func (w *kqueueWatcher) dirChanged(dir string) []string {
+	var out []string
+	for file, f := range w.files {
+		switch {
+		case filepath.Dir(file) == dir:
+			missing := f.fd < 0
+			if w.reopen(file, f, identFile) || (!missing && f.fd < 0) {
+				out = append(out, file)
+				w.scanEntries(file)
+			}
+		case within(file, dir):
+			// Pode ter aparecido um diretorio no caminho do arquivo: re-arma
+			// e avisa se o arquivo ja foi criado.
+			parent := filepath.Dir(file)
+			if _, armed := w.dirs[parent]; armed || w.armDir(parent) != nil {
+				continue
+			}
+			if _, armed := w.dirs[parent]; armed && w.reopen(file, f, identFile) {
+				out = append(out, file)
+				w.scanEntries(file)
+			}
+		}
+	}
+	return out
+}
+
+// dirGone: o diretorio observado foi removido; volta a observar o ancestral
+// existente dos arquivos que dependiam dele.
+func (w *kqueueWatcher) dirGone(dir string, fd int) {
+	delete(w.idents, fd)
+	delete(w.dirs, dir)
+	syscall.Close(fd)
+	for file := range w.files {
+		if within(file, dir) {
+			_ = w.armDir(filepath.Dir(file))
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !freebsd && !darwin
+
+package fswatch
+
+func newWatcher() (Watcher, error) {
+	return nil, ErrUnsupported
+}
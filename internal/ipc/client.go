@@ -0,0 +1,76 @@
+package ipc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"zid-packages/internal/secure"
+)
+
+const (
+	dialTimeout = 2 * time.Second
+	callTimeout = 30 * time.Second
+)
+
+var ErrDaemonUnavailable = errors.New("daemon indisponivel")
+
+// RemoteError e uma resposta com ok=false; Reason e o codigo estavel.
+type RemoteError struct {
+	Op     string
+	Reason string
+	Resp   Response
+}
+
+func (e *RemoteError) Error() string {
+	return "ipc " + e.Op + ": " + e.Reason
+}
+
+// Call assina o request com a chave do host, envia ao daemon e confere a
+// assinatura da resposta.
+func Call(req Request) (Response, error) {
+	key, err := secure.DeriveKey()
+	if err != nil {
+		return Response{}, err
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return Response{}, err
+	}
+	req.TS = time.Now().UTC().Unix()
+	req.Nonce = hex.EncodeToString(nonce[:])
+	req.Sig = ""
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	req.Sig = secure.SignHex(key, payload)
+
+	conn, err := net.DialTimeout("unix", SocketPath, dialTimeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrDaemonUnavailable, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, err
+	}
+	unsigned := resp
+	unsigned.Sig = ""
+	payload, err = json.Marshal(unsigned)
+	if err != nil || !secure.VerifyHex(key, payload, resp.Sig) {
+		return Response{}, errors.New("ipc: assinatura da resposta invalida")
+	}
+	if !resp.OK {
+		return resp, &RemoteError{Op: req.Op, Reason: resp.Reason, Resp: resp}
+	}
+	return resp, nil
+}
@@ -0,0 +1,16 @@
+package ipc
+
+import "zid-packages/internal/packages"
+
+// OpEnableExplain resolve o enable dentro do daemon, com o mesmo cache que o
+// watchdog usa.
+const OpEnableExplain = "ENABLE_EXPLAIN"
+
+func handleEnableExplain(req Request) Response {
+	exp, err := packages.ExplainEnabled(req.Package)
+	if err != nil {
+		return Response{Reason: "unknown_package"}
+	}
+	exp.Origin = "daemon"
+	return Response{OK: true, Explain: &exp}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"zid-packages/internal/jobs"
 	"zid-packages/internal/licensing"
 	"zid-packages/internal/logx"
 	"zid-packages/internal/packages"
@@ -25,22 +26,36 @@ const (
 	defaultReason = "unavailable"
 )
 
+// Os campos omitempty sao usados apenas pelas operacoes de job e nao mudam o
+// payload assinado dos clientes de CHECK.
 type Request struct {
-	Op      string `json:"op"`
-	Package string `json:"package"`
-	TS      int64  `json:"ts"`
-	Nonce   string `json:"nonce"`
-	Sig     string `json:"sig"`
+	Op       string `json:"op"`
+	Package  string `json:"package"`
+	Action   string `json:"action,omitempty"`
+	JobID    string `json:"job_id,omitempty"`
+	FromFile string `json:"from_file,omitempty"`
+	Force    bool   `json:"force,omitempty"`
+	// AllowUnsigned autoriza o update_command de pacote sem bundle assinado.
+	AllowUnsigned bool   `json:"allow_unsigned,omitempty"`
+	Offset        int64  `json:"offset,omitempty"`
+	TS            int64  `json:"ts"`
+	Nonce         string `json:"nonce"`
+	Sig           string `json:"sig"`
 }
 
 type Response struct {
-	OK         bool   `json:"ok"`
-	Licensed   bool   `json:"licensed"`
-	Mode       string `json:"mode"`
-	ValidUntil int64  `json:"valid_until"`
-	Reason     string `json:"reason"`
-	TS         int64  `json:"ts"`
-	Sig        string `json:"sig"`
+	OK         bool                        `json:"ok"`
+	Licensed   bool                        `json:"licensed"`
+	Mode       string                      `json:"mode"`
+	ValidUntil int64                       `json:"valid_until"`
+	Reason     string                      `json:"reason"`
+	Job        *jobs.Job                   `json:"job,omitempty"`
+	Jobs       []jobs.Job                  `json:"jobs,omitempty"`
+	Output     []byte                      `json:"output,omitempty"`
+	Offset     int64                       `json:"offset,omitempty"`
+	Explain    *packages.EnableExplanation `json:"explain,omitempty"`
+	TS         int64                       `json:"ts"`
+	Sig        string                      `json:"sig"`
 }
 
 type Server struct {
@@ -51,6 +66,7 @@ type Server struct {
 	logger   *logx.Logger
 	debug    bool
 	logKeys  bool
+	jobs     *jobs.Manager
 }
 
 func NewServer(logger *logx.Logger) *Server {
@@ -62,6 +78,11 @@ func NewServer(logger *logx.Logger) *Server {
 	}
 }
 
+// SetJobManager habilita as operacoes JOB_* (apenas no daemon).
+func (s *Server) SetJobManager(m *jobs.Manager) {
+	s.jobs = m
+}
+
 func (s *Server) Start() error {
 	if s.listener != nil {
 		return errors.New("ipc already started")
@@ -132,35 +153,37 @@ func (s *Server) handleConn(conn net.Conn) {
 
 func (s *Server) handleRequest(req Request) Response {
 	now := time.Now().UTC()
-	if req.Op != opCheck || req.Package == "" || req.Nonce == "" || req.TS == 0 {
+	switch req.Op {
+	case opCheck:
+		return s.handleCheck(req, now)
+	case OpJobSubmit, OpJobList, OpJobShow, OpJobCancel, OpJobLogs, OpEnableExplain:
+		if req.Nonce == "" || req.TS == 0 {
+			return s.respond(req, Response{Mode: licensing.ModeNeverOK, Reason: "invalid_request", TS: now.Unix()})
+		}
+		if reason, ok := s.authenticate(req, now); !ok {
+			return s.respond(req, Response{Mode: licensing.ModeNeverOK, Reason: reason, TS: now.Unix()})
+		}
+		var resp Response
+		if req.Op == OpEnableExplain {
+			resp = handleEnableExplain(req)
+		} else {
+			resp = s.handleJob(req)
+		}
+		resp.TS = now.Unix()
+		return s.respond(req, resp)
+	}
+	return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "invalid_request", TS: now.Unix()})
+}
+
+func (s *Server) handleCheck(req Request, now time.Time) Response {
+	if req.Package == "" || req.Nonce == "" || req.TS == 0 {
 		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "invalid_request", TS: now.Unix()})
 	}
 	if err := packages.ValidateKey(req.Package); err != nil {
 		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "unknown_package", TS: now.Unix()})
 	}
-	if skew := now.Sub(time.Unix(req.TS, 0)); skew < -maxTimeSkew || skew > maxTimeSkew {
-		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "invalid_ts", TS: now.Unix()})
-	}
-	if !s.acceptNonce(req.Nonce, now) {
-		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "replay", TS: now.Unix()})
-	}
-
-	key, err := secure.DeriveKey()
-	if err != nil {
-		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: defaultReason, TS: now.Unix()})
-	}
-	if s.logKeys {
-		s.logInfo("ipc key hex=" + hex.EncodeToString(key))
-	}
-
-	unsigned := req
-	unsigned.Sig = ""
-	payload, err := json.Marshal(unsigned)
-	if err != nil || !secure.VerifyHex(key, payload, req.Sig) {
-		if s.debug && err == nil {
-			s.logInfo("ipc bad_sig expected=" + secure.SignHex(key, payload))
-		}
-		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: "bad_sig", TS: now.Unix()})
+	if reason, ok := s.authenticate(req, now); !ok {
+		return s.respond(req, Response{OK: false, Licensed: false, Mode: licensing.ModeNeverOK, Reason: reason, TS: now.Unix()})
 	}
 
 	st, err := licensing.LoadState()
@@ -189,6 +212,35 @@ func (s *Server) handleRequest(req Request) Response {
 	return s.respond(req, resp)
 }
 
+// authenticate confere ts, nonce e a assinatura HMAC do request.
+func (s *Server) authenticate(req Request, now time.Time) (string, bool) {
+	if skew := now.Sub(time.Unix(req.TS, 0)); skew < -maxTimeSkew || skew > maxTimeSkew {
+		return "invalid_ts", false
+	}
+	if !s.acceptNonce(req.Nonce, now) {
+		return "replay", false
+	}
+
+	key, err := secure.DeriveKey()
+	if err != nil {
+		return defaultReason, false
+	}
+	if s.logKeys {
+		s.logInfo("ipc key hex=" + hex.EncodeToString(key))
+	}
+
+	unsigned := req
+	unsigned.Sig = ""
+	payload, err := json.Marshal(unsigned)
+	if err != nil || !secure.VerifyHex(key, payload, req.Sig) {
+		if s.debug && err == nil {
+			s.logInfo("ipc bad_sig expected=" + secure.SignHex(key, payload))
+		}
+		return "bad_sig", false
+	}
+	return "", true
+}
+
 func (s *Server) signResponse(resp Response) Response {
 	key, err := secure.DeriveKey()
 	if err != nil {
@@ -0,0 +1,108 @@
+package ipc
+
+import (
+	"errors"
+	"path/filepath"
+
+	"zid-packages/internal/jobs"
+	"zid-packages/internal/packages"
+)
+
+const (
+	OpJobSubmit = "JOB_SUBMIT"
+	OpJobList   = "JOB_LIST"
+	OpJobShow   = "JOB_SHOW"
+	OpJobCancel = "JOB_CANCEL"
+	OpJobLogs   = "JOB_LOGS"
+)
+
+func (s *Server) handleJob(req Request) Response {
+	if s.jobs == nil {
+		return Response{Reason: "jobs_unavailable"}
+	}
+	switch req.Op {
+	case OpJobSubmit:
+		return s.submitJob(req)
+	case OpJobList:
+		return Response{OK: true, Jobs: s.jobs.List()}
+	}
+
+	if !jobs.ValidID(req.JobID) {
+		return Response{Reason: "invalid_request"}
+	}
+	switch req.Op {
+	case OpJobShow:
+		job, ok := s.jobs.Get(req.JobID)
+		if !ok {
+			return Response{Reason: "not_found"}
+		}
+		return Response{OK: true, Job: &job}
+	case OpJobCancel:
+		job, err := s.jobs.Cancel(req.JobID)
+		if err != nil {
+			return Response{Reason: jobReason(err)}
+		}
+		return Response{OK: true, Job: &job}
+	case OpJobLogs:
+		out, next, err := s.jobs.Logs(req.JobID, req.Offset)
+		if err != nil {
+			return Response{Reason: jobReason(err)}
+		}
+		job, _ := s.jobs.Get(req.JobID)
+		return Response{OK: true, Job: &job, Output: out, Offset: next}
+	}
+	return Response{Reason: "invalid_request"}
+}
+
+func (s *Server) submitJob(req Request) Response {
+	if req.Action != "install" && req.Action != "update" {
+		return Response{Reason: "invalid_request"}
+	}
+	if err := packages.ValidateKey(req.Package); err != nil {
+		return Response{Reason: "unknown_package"}
+	}
+	args := []string{}
+	if req.FromFile != "" {
+		if !filepath.IsAbs(req.FromFile) {
+			return Response{Reason: "invalid_request"}
+		}
+		args = append(args, "--from-file", filepath.Clean(req.FromFile))
+	}
+	if req.Force {
+		if req.Action != "update" {
+			return Response{Reason: "invalid_request"}
+		}
+		args = append(args, "--force")
+	}
+	if req.AllowUnsigned {
+		if req.Action != "update" || req.FromFile != "" {
+			return Response{Reason: "invalid_request"}
+		}
+		args = append(args, "--allow-unsigned")
+	}
+	job, err := s.jobs.Submit(req.Action, req.Package, args)
+	if err != nil {
+		resp := Response{Reason: jobReason(err)}
+		if job.ID != "" {
+			resp.Job = &job
+		}
+		return resp
+	}
+	s.logInfo("ipc job submit: " + job.ID + " " + job.Action + " " + job.Package)
+	return Response{OK: true, Job: &job}
+}
+
+func jobReason(err error) string {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, jobs.ErrFinished):
+		return "finished"
+	case errors.Is(err, jobs.ErrActive):
+		return "job_active"
+	case errors.Is(err, jobs.ErrQueueFull):
+		return "queue_full"
+	default:
+		return "job_error"
+	}
+}
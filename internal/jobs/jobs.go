@@ -0,0 +1,489 @@
+// Package jobs mantem a fila de operacoes de pacote (install/update) executadas
+// pelo daemon. Cada job roda como processo filho do proprio zid-packages, com a
+// saida em <id>.log e o estado em <id>.json, e sobrevive a restart do daemon.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zid-packages/internal/logx"
+)
+
+const (
+	Dir = "/var/db/zid-packages/jobs"
+
+	// EnvJobID e EnvExitFile sao definidos no processo do job.
+	EnvJobID    = "ZID_PACKAGES_JOB"
+	EnvExitFile = "ZID_PACKAGES_JOB_EXIT"
+
+	keepFinished = 50
+	queueSize    = 64
+	logChunk     = 64 * 1024
+	adoptPoll    = 2 * time.Second
+)
+
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+var (
+	ErrNotFound  = errors.New("job nao encontrado")
+	ErrFinished  = errors.New("job ja terminou")
+	ErrActive    = errors.New("ja existe job ativo para o pacote")
+	ErrQueueFull = errors.New("fila de jobs cheia")
+)
+
+type Job struct {
+	ID              string   `json:"id"`
+	Action          string   `json:"action"`
+	Package         string   `json:"package"`
+	Args            []string `json:"args,omitempty"`
+	State           string   `json:"state"`
+	CreatedAt       int64    `json:"created_at"`
+	StartedAt       int64    `json:"started_at,omitempty"`
+	EndedAt         int64    `json:"ended_at,omitempty"`
+	ExitCode        *int     `json:"exit_code,omitempty"`
+	PID             int      `json:"pid,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	CancelRequested bool     `json:"cancel_requested,omitempty"`
+}
+
+func (j Job) Finished() bool {
+	return j.State == StateSucceeded || j.State == StateFailed || j.State == StateCancelled
+}
+
+// CommandFunc devolve o argv do processo que executa o job.
+type CommandFunc func(job Job) []string
+
+type Manager struct {
+	dir     string
+	logger  *logx.Logger
+	command CommandFunc
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	queue chan string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func NewManager(dir string, logger *logx.Logger, command CommandFunc) *Manager {
+	return &Manager{
+		dir:     dir,
+		logger:  logger,
+		command: command,
+		jobs:    map[string]*Job{},
+		queue:   make(chan string, queueSize),
+	}
+}
+
+// Start carrega os jobs gravados: os que estavam rodando quando o daemon parou
+// sao acompanhados ate terminar e os da fila voltam para a fila.
+func (m *Manager) Start() error {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+	loaded, err := m.load()
+	if err != nil {
+		return err
+	}
+	var adopted []string
+	m.mu.Lock()
+	for _, job := range loaded {
+		j := job
+		m.jobs[j.ID] = &j
+		switch j.State {
+		case StateRunning:
+			adopted = append(adopted, j.ID)
+		case StateQueued:
+			m.queue <- j.ID
+		}
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	m.stop, m.done = stop, done
+	m.mu.Unlock()
+	go m.loop(adopted, stop, done)
+	return nil
+}
+
+// Stop encerra o worker sem matar o job em execucao; ele e retomado no
+// proximo Start (ex.: update do proprio zid-packages reinicia o daemon).
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop = nil
+	m.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (m *Manager) Submit(action, pkg string, args []string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.Package == pkg && !j.Finished() {
+			return *j, fmt.Errorf("%w: %s (%s)", ErrActive, pkg, j.ID)
+		}
+	}
+	job := &Job{
+		ID:        newID(),
+		Action:    action,
+		Package:   pkg,
+		Args:      append([]string(nil), args...),
+		State:     StateQueued,
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+	if err := m.save(job); err != nil {
+		return Job{}, err
+	}
+	select {
+	case m.queue <- job.ID:
+	default:
+		_ = os.Remove(m.path(job.ID, ".json"))
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[job.ID] = job
+	m.logInfo("job queued: " + job.ID + " " + action + " " + pkg)
+	return *job, nil
+}
+
+// List devolve os jobs do mais recente para o mais antigo.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, *j)
+	}
+	sortNewestFirst(out)
+	return out
+}
+
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Cancel tira o job da fila ou envia SIGTERM ao grupo de processos dele.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	switch j.State {
+	case StateQueued:
+		j.State = StateCancelled
+		j.EndedAt = time.Now().UTC().Unix()
+	case StateRunning:
+		j.CancelRequested = true
+		if j.PID > 0 {
+			_ = syscall.Kill(-j.PID, syscall.SIGTERM)
+		}
+	default:
+		return *j, ErrFinished
+	}
+	m.logInfo("job cancel: " + id)
+	return *j, m.save(j)
+}
+
+// Logs devolve a saida do job a partir de offset (no maximo logChunk bytes) e
+// o offset seguinte.
+func (m *Manager) Logs(id string, offset int64) ([]byte, int64, error) {
+	if _, ok := m.Get(id); !ok {
+		return nil, offset, ErrNotFound
+	}
+	f, err := os.Open(m.path(id, ".log"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, offset, nil
+		}
+		return nil, offset, err
+	}
+	defer f.Close()
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+	buf := make([]byte, logChunk)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, offset, err
+	}
+	return buf[:n], offset + int64(n), nil
+}
+
+func (m *Manager) loop(adopted []string, stop, done chan struct{}) {
+	defer close(done)
+	for _, id := range adopted {
+		if !m.adopt(id, stop) {
+			return
+		}
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case id := <-m.queue:
+			if !m.run(id, stop) {
+				return
+			}
+		}
+	}
+}
+
+// run executa o job; devolve false se o daemon esta parando.
+func (m *Manager) run(id string, stop chan struct{}) bool {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok || j.State != StateQueued {
+		m.mu.Unlock()
+		return true
+	}
+	job := *j
+	m.mu.Unlock()
+
+	logFile, err := os.OpenFile(m.path(id, ".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		m.finish(id, -1, err)
+		return true
+	}
+	defer logFile.Close()
+	exitFile := m.path(id, ".exit")
+	_ = os.Remove(exitFile)
+
+	// O shell grava o exit code para que um daemon reiniciado saiba o
+	// resultado de um job que nao e mais seu filho.
+	argv := append([]string{"-c", `rc=0; "$@" || rc=$?; echo "$rc" > "$` + EnvExitFile + `"; exit "$rc"`, "sh"}, m.command(job)...)
+	cmd := exec.Command("/bin/sh", argv...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Env = append(os.Environ(), EnvJobID+"="+id, EnvExitFile+"="+exitFile)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		m.finish(id, -1, err)
+		return true
+	}
+
+	m.mu.Lock()
+	j.State = StateRunning
+	j.PID = cmd.Process.Pid
+	j.StartedAt = time.Now().UTC().Unix()
+	_ = m.save(j)
+	m.mu.Unlock()
+	m.logInfo("job start: " + id + " pid=" + strconv.Itoa(cmd.Process.Pid))
+
+	waited := make(chan error, 1)
+	go func() { waited <- cmd.Wait() }()
+	select {
+	case err := <-waited:
+		code := -1
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+		m.finish(id, code, err)
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// adopt acompanha um job iniciado por uma instancia anterior do daemon.
+func (m *Manager) adopt(id string, stop chan struct{}) bool {
+	m.mu.Lock()
+	pid := m.jobs[id].PID
+	m.mu.Unlock()
+	exitFile := m.path(id, ".exit")
+	ticker := time.NewTicker(adoptPoll)
+	defer ticker.Stop()
+	for {
+		if code, ok := readExitFile(exitFile); ok {
+			var err error
+			if code != 0 {
+				err = fmt.Errorf("exit status %d", code)
+			}
+			m.finish(id, code, err)
+			return true
+		}
+		if pid <= 0 || !processAlive(pid) {
+			m.finish(id, -1, errors.New("processo do job terminou sem resultado (daemon reiniciado)"))
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) finish(id string, code int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	j.EndedAt = time.Now().UTC().Unix()
+	if code >= 0 {
+		c := code
+		j.ExitCode = &c
+	}
+	switch {
+	case j.CancelRequested:
+		j.State = StateCancelled
+	case err == nil && code == 0:
+		j.State = StateSucceeded
+	default:
+		j.State = StateFailed
+		if err != nil {
+			j.Error = err.Error()
+		}
+	}
+	_ = m.save(j)
+	m.logInfo("job " + j.State + ": " + id)
+	m.prune()
+}
+
+// prune remove os jobs terminados mais antigos alem de keepFinished.
+func (m *Manager) prune() {
+	finished := []Job{}
+	for _, j := range m.jobs {
+		if j.Finished() {
+			finished = append(finished, *j)
+		}
+	}
+	sortNewestFirst(finished)
+	for i := keepFinished; i < len(finished); i++ {
+		id := finished[i].ID
+		delete(m.jobs, id)
+		for _, ext := range []string{".json", ".log", ".exit"} {
+			_ = os.Remove(m.path(id, ext))
+		}
+	}
+}
+
+func (m *Manager) load() ([]Job, error) {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := []Job{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var j Job
+		if err := json.Unmarshal(data, &j); err != nil || j.ID == "" {
+			m.logInfo("job invalido ignorado: " + file)
+			continue
+		}
+		out = append(out, j)
+	}
+	// Mantem a ordem de submissao ao recolocar na fila.
+	sort.SliceStable(out, func(a, b int) bool {
+		if out[a].CreatedAt != out[b].CreatedAt {
+			return out[a].CreatedAt < out[b].CreatedAt
+		}
+		return out[a].ID < out[b].ID
+	})
+	return out, nil
+}
+
+func (m *Manager) save(j *Job) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := m.path(j.ID, ".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *Manager) path(id, ext string) string {
+	return filepath.Join(m.dir, id+ext)
+}
+
+func (m *Manager) logInfo(msg string) {
+	if m.logger != nil {
+		m.logger.Info(msg)
+	}
+}
+
+func readExitFile(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func sortNewestFirst(jobs []Job) {
+	sort.SliceStable(jobs, func(a, b int) bool {
+		if jobs[a].CreatedAt != jobs[b].CreatedAt {
+			return jobs[a].CreatedAt > jobs[b].CreatedAt
+		}
+		return jobs[a].ID > jobs[b].ID
+	})
+}
+
+func newID() string {
+	var b [3]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// ValidID evita path traversal em ids recebidos pelo IPC.
+func ValidID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
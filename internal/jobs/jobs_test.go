@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shellCommand(script string) CommandFunc {
+	return func(job Job) []string {
+		return []string{"/bin/sh", "-c", script}
+	}
+}
+
+func waitFinished(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := m.Get(id); ok && j.Finished() {
+			return j
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	j, _ := m.Get(id)
+	t.Fatalf("job %s nao terminou: %+v", id, j)
+	return Job{}
+}
+
+func TestManager_RunCapturesOutputAndExitCode(t *testing.T) {
+	m := NewManager(t.TempDir(), nil, shellCommand(`echo "job=$ZID_PACKAGES_JOB"; exit 3`))
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	job, err := m.Submit("update", "zid-proxy", nil)
+	if err != nil {
+		t.Fatalf("Submit() err=%v", err)
+	}
+	got := waitFinished(t, m, job.ID)
+	if got.State != StateFailed || got.ExitCode == nil || *got.ExitCode != 3 {
+		t.Fatalf("job=%+v; want failed exit 3", got)
+	}
+	if got.StartedAt == 0 || got.EndedAt == 0 {
+		t.Fatalf("job sem horarios: %+v", got)
+	}
+	out, next, err := m.Logs(job.ID, 0)
+	if err != nil || !strings.Contains(string(out), "job="+job.ID) {
+		t.Fatalf("Logs()=%q, %v; want id do job", out, err)
+	}
+	if rest, _, _ := m.Logs(job.ID, next); len(rest) != 0 {
+		t.Fatalf("Logs(offset=%d)=%q; want vazio", next, rest)
+	}
+}
+
+func TestManager_RejectsSecondActiveJob(t *testing.T) {
+	m := NewManager(t.TempDir(), nil, shellCommand("sleep 5"))
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	first, err := m.Submit("update", "zid-proxy", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Submit("install", "zid-proxy", nil); err == nil {
+		t.Fatalf("Submit() com job ativo; want erro")
+	}
+	if _, err := m.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFinished(t, m, first.ID)
+}
+
+func TestManager_CancelRunningAndQueued(t *testing.T) {
+	m := NewManager(t.TempDir(), nil, shellCommand("sleep 30"))
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	running, _ := m.Submit("update", "zid-proxy", nil)
+	queued, _ := m.Submit("update", "zid-logs", nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if j, _ := m.Get(running.ID); j.State == StateRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job nao iniciou")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if j, err := m.Cancel(queued.ID); err != nil || j.State != StateCancelled {
+		t.Fatalf("Cancel(queued)=%+v, %v; want cancelled", j, err)
+	}
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j := waitFinished(t, m, running.ID); j.State != StateCancelled {
+		t.Fatalf("job=%+v; want cancelled", j)
+	}
+	if _, err := m.Cancel(running.ID); err != ErrFinished {
+		t.Fatalf("Cancel(finished) err=%v; want ErrFinished", err)
+	}
+}
+
+func TestManager_ResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, nil, shellCommand("exit 0"))
+	// Job em execucao por um daemon anterior: processo ja terminou e deixou o
+	// exit code; job na fila volta a rodar.
+	adopted := &Job{ID: "20260101-000000-aaaaaa", Action: "update", Package: "zid-proxy", State: StateRunning, CreatedAt: 1, PID: 999999999}
+	queued := &Job{ID: "20260101-000001-bbbbbb", Action: "install", Package: "zid-logs", State: StateQueued, CreatedAt: 2}
+	for _, j := range []*Job{adopted, queued} {
+		if err := m.save(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(m.path(adopted.ID, ".exit"), []byte("0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	if j := waitFinished(t, m, adopted.ID); j.State != StateSucceeded {
+		t.Fatalf("adotado=%+v; want succeeded", j)
+	}
+	if j := waitFinished(t, m, queued.ID); j.State != StateSucceeded {
+		t.Fatalf("fila=%+v; want succeeded", j)
+	}
+}
+
+func TestValidID(t *testing.T) {
+	for _, id := range []string{"20261018-153012-ab12cd"} {
+		if !ValidID(id) {
+			t.Fatalf("ValidID(%q)=false", id)
+		}
+	}
+	for _, id := range []string{"", "../etc/passwd", "ABC", "x y"} {
+		if ValidID(id) {
+			t.Fatalf("ValidID(%q)=true", id)
+		}
+	}
+}
@@ -190,3 +190,17 @@ func Evaluate(st state.LicenseState, now time.Time) (string, time.Time) {
 	}
 	return ModeOK, validUntil
 }
+
+// Forget remove o pacote do estado de licenca local; o proximo Sync volta a
+// preenche-lo se o pacote for reinstalado.
+func Forget(key string) error {
+	st, err := state.Load(state.DefaultPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := st.Licensed[key]; !ok {
+		return nil
+	}
+	delete(st.Licensed, key)
+	return state.Save(state.DefaultPath, st)
+}
@@ -0,0 +1,180 @@
+// Package lock serializa operacoes entre processos (daemon, cron, CLI, jobs)
+// com flock em arquivos por escopo. O kernel libera o flock quando o processo
+// morre; o conteudo do arquivo (pid, operacao, inicio) serve para mensagens e
+// para detectar locks abandonados por processos que cairam.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Global serializa as rodadas do watchdog e do auto-update.
+const Global = "global"
+
+var Dir = "/var/run/zid-packages/locks"
+
+const (
+	// Um Held concorrente segura o flock compartilhado por instantes; Acquire
+	// tenta de novo antes de desistir se o arquivo nao tem dono gravado.
+	probeRetry    = 50 * time.Millisecond
+	probeAttempts = 20
+	waitInterval  = 500 * time.Millisecond
+)
+
+var ErrLocked = errors.New("lock em uso")
+
+type Info struct {
+	PID   int    `json:"pid"`
+	Op    string `json:"op"`
+	Since int64  `json:"since"`
+}
+
+type LockedError struct {
+	Scope string
+	Info  Info
+}
+
+func (e *LockedError) Error() string {
+	if e.Info.PID == 0 {
+		return e.Scope + " em uso"
+	}
+	since := time.Unix(e.Info.Since, 0).Format("2006-01-02 15:04:05")
+	return fmt.Sprintf("%s em uso por %q (pid %d, desde %s)", e.Scope, e.Info.Op, e.Info.PID, since)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+type Lock struct {
+	scope     string
+	f         *os.File
+	recovered Info
+}
+
+// Package e o escopo de manutencao de um pacote.
+func Package(key string) string {
+	return "package:" + key
+}
+
+// Acquire tenta o lock sem bloquear; se outro processo o detem, devolve
+// *LockedError com o dono.
+func Acquire(scope, op string) (*Lock, error) {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path(scope), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, err
+		}
+		if info := readInfo(f); info.PID != 0 || attempt >= probeAttempts {
+			f.Close()
+			return nil, &LockedError{Scope: scope, Info: info}
+		}
+		time.Sleep(probeRetry)
+	}
+	l := &Lock{scope: scope, f: f}
+	// Conteudo sobrando com o flock livre: o dono anterior caiu sem Release.
+	if prev := readInfo(f); prev.PID != 0 {
+		l.recovered = prev
+	}
+	if err := writeInfo(f, Info{PID: os.Getpid(), Op: op, Since: time.Now().Unix()}); err != nil {
+		l.Release()
+		return nil, err
+	}
+	return l, nil
+}
+
+// AcquireWait tenta Acquire ate timeout.
+func AcquireWait(scope, op string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := Acquire(scope, op)
+		if err == nil || !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			return l, err
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+// Recovered devolve o dono anterior quando o lock foi abandonado (processo
+// morto sem liberar).
+func (l *Lock) Recovered() (Info, bool) {
+	return l.recovered, l.recovered.PID != 0
+}
+
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	_ = l.f.Truncate(0)
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// Held informa se algum processo detem o escopo, sem adquiri-lo.
+func Held(scope string) (Info, bool) {
+	f, err := os.Open(path(scope))
+	if err != nil {
+		return Info{}, false
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return readInfo(f), errors.Is(err, syscall.EWOULDBLOCK)
+	}
+	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return Info{}, false
+}
+
+func path(scope string) string {
+	name := strings.NewReplacer(":", "_", "/", "_", string(filepath.Separator), "_").Replace(scope)
+	return filepath.Join(Dir, name+".lock")
+}
+
+func readInfo(f *os.File) Info {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Info{}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 4096))
+	if err != nil || len(data) == 0 {
+		return Info{}
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return Info{}
+	}
+	return info
+}
+
+func writeInfo(f *os.File, info Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(append(data, '\n'), 0)
+	return err
+}
@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func withDir(t *testing.T) {
+	t.Helper()
+	orig := Dir
+	Dir = t.TempDir()
+	t.Cleanup(func() { Dir = orig })
+}
+
+func TestAcquire_Exclusive(t *testing.T) {
+	withDir(t)
+	scope := Package("zid-proxy")
+	l, err := Acquire(scope, "update zid-proxy")
+	if err != nil {
+		t.Fatalf("Acquire() err=%v", err)
+	}
+	_, err = Acquire(scope, "install zid-proxy")
+	var lerr *LockedError
+	if !errors.As(err, &lerr) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("Acquire() segundo=%v; want LockedError", err)
+	}
+	if lerr.Info.PID != os.Getpid() || lerr.Info.Op != "update zid-proxy" {
+		t.Fatalf("LockedError.Info=%+v; want dono atual", lerr.Info)
+	}
+	if info, held := Held(scope); !held || info.Op != "update zid-proxy" {
+		t.Fatalf("Held()=%+v,%v; want true", info, held)
+	}
+	other, err := Acquire(Package("zid-logs"), "update zid-logs")
+	if err != nil {
+		t.Fatalf("Acquire(outro escopo) err=%v", err)
+	}
+	other.Release()
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, held := Held(scope); held {
+		t.Fatalf("Held() apos Release=true")
+	}
+	l2, err := Acquire(scope, "install zid-proxy")
+	if err != nil {
+		t.Fatalf("Acquire() apos Release err=%v", err)
+	}
+	if _, ok := l2.Recovered(); ok {
+		t.Fatalf("Recovered() apos Release limpo=true")
+	}
+	l2.Release()
+}
+
+func TestAcquire_RecoversAbandonedLock(t *testing.T) {
+	withDir(t)
+	// Arquivo com dono gravado mas sem flock: processo caiu sem Release.
+	if err := os.WriteFile(path(Global), []byte(`{"pid":999999,"op":"auto-update","since":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Acquire(Global, "watchdog")
+	if err != nil {
+		t.Fatalf("Acquire() err=%v", err)
+	}
+	defer l.Release()
+	prev, ok := l.Recovered()
+	if !ok || prev.PID != 999999 || prev.Op != "auto-update" {
+		t.Fatalf("Recovered()=%+v,%v; want dono abandonado", prev, ok)
+	}
+}
+
+func TestAcquireWait(t *testing.T) {
+	withDir(t)
+	l, err := Acquire(Global, "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		l.Release()
+	}()
+	l2, err := AcquireWait(Global, "auto-update", 5*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireWait() err=%v", err)
+	}
+	l2.Release()
+	l3, err := AcquireWait(Global, "x", 0)
+	if err != nil {
+		t.Fatalf("AcquireWait(livre) err=%v", err)
+	}
+	l3.Release()
+}
@@ -0,0 +1,247 @@
+package packages
+
+import "strings"
+
+const (
+	proxyBin        = "/usr/local/sbin/zid-proxy"
+	appidBin        = "/usr/local/sbin/zid-appid"
+	threatdBin      = "/usr/local/sbin/zid-threatd"
+	geolocationBin  = "/usr/local/sbin/zid-geolocation"
+	logsBin         = "/usr/local/sbin/zid-logs"
+	accessBin       = "/usr/local/sbin/zidaccess"
+	orchestratorBin = "/usr/local/sbin/zid-orchestration"
+	packagesBin     = "/usr/local/sbin/zid-packages"
+)
+
+const controllerAppID = "zid-appid"
+
+var builtin = []Package{
+	{
+		Key:               "zid-packages",
+		Name:              "ZID Packages",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-packages-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-packages-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-packages-update",
+		InstallScriptGlob: "*/scripts/install.sh",
+		Binary:            packagesBin,
+		Enable:            EnableChain{Sources: rcConfEnableSources("zid_packages", "zid_packages_enable")},
+		Version: []VersionSource{
+			{Kind: VersionKindConfigPackage, Names: []string{"zid-packages"}},
+			{Kind: VersionKindBinary, File: packagesBin},
+		},
+		Services: []Service{
+			{Key: "zid-packages", RCScript: "/usr/local/etc/rc.d/zid_packages", StartVerb: "onestart", StopVerb: "onestop", Pgrep: "^/usr/local/sbin/zid-packages daemon", Unsupervised: true},
+		},
+	},
+	{
+		Key:               "zid-proxy",
+		Name:              "ZID Proxy",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-proxy-pfsense-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-proxy-pfsense-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-proxy-update",
+		InstallScriptGlob: "*/pkg-zid-proxy/install.sh",
+		UninstallScript:   "/usr/local/share/pfSense-pkg-zid-proxy/uninstall.sh",
+		Binary:            proxyBin,
+		Enable:            EnableChain{Cache: true, Sources: configEnableSources("zidproxy", "enable")},
+		Version: []VersionSource{
+			{Kind: VersionKindBinary, File: proxyBin},
+		},
+		Services: []Service{
+			{
+				Key:       "zid-proxy",
+				RCScript:  "/usr/local/etc/rc.d/zid-proxy.sh",
+				StartVerb: "start",
+				StopVerb:  "stop",
+				Pgrep:     "^/usr/local/sbin/zid-proxy",
+				PostStop:  &PHPHook{Include: "/usr/local/pkg/zid-proxy.inc", Function: "zidproxy_service_poststop_hook"},
+			},
+			{Key: "zid-appid", Binary: appidBin, Pgrep: "^/usr/local/sbin/zid-appid", Controller: controllerAppID, Depends: []string{"zid-proxy"}},
+			{
+				Key:       "zid-threatd",
+				Binary:    threatdBin,
+				RCScript:  "/usr/local/etc/rc.d/zid-threatd",
+				StartVerb: "start",
+				StopVerb:  "stop",
+				Pgrep:     "^/usr/local/sbin/zid-threatd",
+				Depends:   []string{"zid-proxy"},
+				Enable:    EnableChain{Cache: true, Sources: configEnableSources("zidproxy", "threat_enable")},
+			},
+		},
+	},
+	{
+		Key:               "zid-geolocation",
+		Name:              "ZID Geolocation",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-geolocation-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-geolocation-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-geolocation-update",
+		InstallScriptGlob: "*/scripts/install.sh",
+		UninstallScript:   "/usr/local/share/pfSense-pkg-zid-geolocation/uninstall.sh",
+		Binary:            geolocationBin,
+		Enable: EnableChain{Cache: true, Sources: append(configEnableSources("zidgeolocation", "enable"),
+			JSONEnable{File: "/usr/local/etc/zid-geolocation/config.json", Key: "enable"},
+		)},
+		Version: []VersionSource{
+			{Kind: VersionKindBinary, File: geolocationBin},
+		},
+		Services: []Service{
+			{
+				Key:       "zid-geolocation",
+				RCScript:  "/usr/local/etc/rc.d/zid_geolocation",
+				StartVerb: "onestart",
+				StopVerb:  "onestop",
+				Pgrep:     "^/usr/local/sbin/zid-geolocation",
+				PostStart: &PHPHook{Include: "/usr/local/pkg/zid-geolocation.inc", Function: "zid_geolocation_apply_async"},
+				PostStop:  &PHPHook{Include: "/usr/local/pkg/zid-geolocation.inc", Function: "zid_geolocation_clear_floating_rules"},
+			},
+		},
+	},
+	{
+		Key:               "zid-logs",
+		Name:              "ZID Logs",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-logs-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-logs-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-logs-update",
+		InstallScriptGlob: "*/pkg-zid-logs/install.sh",
+		UninstallScript:   "/usr/local/share/pfSense-pkg-zid-logs/uninstall.sh",
+		Binary:            logsBin,
+		Enable: EnableChain{Sources: []EnableSource{
+			JSONEnable{File: "/usr/local/etc/zid-logs/config.json", Key: "enabled"},
+		}},
+		// config.xml pode conter version "dev" (ex.: "zid-logs version dev") dependendo de como o pacote foi registrado.
+		// Para exibir/comparar updates, precisamos de uma versao numerica.
+		Version: []VersionSource{
+			{Kind: VersionKindPackageXML, File: "/usr/local/pkg/zid-logs.xml"},
+			{Kind: VersionKindFile, File: "/usr/local/share/pfSense-pkg-zid-logs/VERSION"},
+			{Kind: VersionKindConfigPackage, Names: []string{"zid-logs"}, Numeric: true},
+			{Kind: VersionKindBinary, File: logsBin},
+		},
+		Services: []Service{
+			{Key: "zid-logs", RCScript: "/usr/local/etc/rc.d/zid_logs", StartVerb: "onestart", StopVerb: "onestop", Pgrep: "^/usr/local/sbin/zid-logs"},
+		},
+	},
+	{
+		Key:               "zid-access",
+		Name:              "ZID Access",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-access-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-access-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-access-update",
+		InstallScriptGlob: "*/pkg-zid-access/install.sh",
+		UninstallScript:   "/usr/local/share/pfSense-pkg-zid-access/uninstall.sh",
+		Binary:            accessBin,
+		Enable:            EnableChain{Cache: true, Sources: accessEnableSources()},
+		Version: []VersionSource{
+			{Kind: VersionKindConfigPackage, Names: []string{"zid-access"}},
+			{Kind: VersionKindFile, File: "/usr/local/share/pfSense-pkg-zid-access/VERSION"},
+		},
+		Services: []Service{
+			{Key: "zid-access", RCScript: "/usr/local/etc/rc.d/zid-access.sh", StartVerb: "start", StopVerb: "stop", Pgrep: "/usr/local/sbin/zidaccess"},
+		},
+	},
+	{
+		Key:               "zid-orchestrator",
+		Name:              "ZID Orchestrator",
+		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-orchestrator-latest.tar.gz",
+		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-orchestrator-latest.version",
+		UpdateCommand:     "/usr/local/sbin/zid-orchestrator-update",
+		InstallScriptGlob: "*/pkg/pfSense-pkg-zid-orchestration/scripts/post-install",
+		UninstallScript:   "/usr/local/share/pfSense-pkg-zid-orchestration/uninstall.sh",
+		Binary:            orchestratorBin,
+		Depends:           []string{"zid-proxy", "zid-geolocation", "zid-logs", "zid-access"},
+		Enable:            EnableChain{Sources: rcConfEnableSources("zid_orchestration", "zid_orchestration_enable")},
+		// O arquivo VERSION e o binario refletem a versao instalada de fato; o
+		// registro no config.xml pode ficar desatualizado apos updates manuais.
+		Version: []VersionSource{
+			{Kind: VersionKindFile, File: "/usr/local/share/pfSense-pkg-zid-orchestration/VERSION"},
+			{Kind: VersionKindBinary, File: orchestratorBin},
+			{Kind: VersionKindConfigPackage, Names: []string{"zid-orchestrator", "zid-orchestration"}, Numeric: true},
+			{Kind: VersionKindConfigPackage, Names: []string{"zid-orchestrator", "zid-orchestration"}},
+		},
+		Services: []Service{
+			{Key: "zid-orchestrator", RCScript: "/usr/local/etc/rc.d/zid_orchestration", StartVerb: "onestart", StopVerb: "onestop", Pgrep: "^/usr/local/sbin/zid-orchestration"},
+		},
+	},
+}
+
+// configEnableSources gera a ordem padrao de pacotes com GUI no pfSense: o
+// PHP do proprio pfSense, match estrito no config.xml com e sem
+// <installedpackages> e por fim o matcher loose.
+func configEnableSources(section, key string) []EnableSource {
+	full := []string{"installedpackages", section, "config", key}
+	short := []string{section, "config", key}
+	return []EnableSource{
+		PHPEnable{Name: strings.Join(full, "/"), Expr: phpEnableExpr(section, key)},
+		ConfigXMLEnable{Path: full},
+		ConfigXMLEnable{Path: short},
+		ConfigXMLEnable{Path: full, Loose: true},
+		ConfigXMLEnable{Path: short, Loose: true},
+	}
+}
+
+// rcConfEnableSources le a variavel com a precedencia do rc.subr para o rc
+// script name (rc.conf, rc.conf.local, rc.conf.d/<name>).
+func rcConfEnableSources(name, key string) []EnableSource {
+	return []EnableSource{RCEnable{Name: name, Key: key}}
+}
+
+func accessEnableSources() []EnableSource {
+	sections := []string{"zidaccess", "zid-access", "zid_access"}
+	out := []EnableSource{
+		PHPEnable{Name: "installedpackages/(zidaccess|zid-access|zid_access)/config/enable", Expr: accessPHPEnableExpr},
+	}
+	for _, section := range sections {
+		out = append(out,
+			ConfigXMLEnable{Path: []string{"installedpackages", section, "config", "enable"}},
+			ConfigXMLEnable{Path: []string{section, "config", "enable"}},
+		)
+	}
+	// Formato quebrado (legado): lista escalar sem chaves (gera <config>valor</config> repetido).
+	// Nesse caso o primeiro <config> costuma ser o enable.
+	for _, section := range sections {
+		out = append(out,
+			LegacyListEnable{Path: []string{"installedpackages", section, "config"}},
+			LegacyListEnable{Path: []string{section, "config"}},
+		)
+	}
+	for _, section := range sections {
+		out = append(out,
+			ConfigXMLEnable{Path: []string{"installedpackages", section, "config", "enable"}, Loose: true},
+			ConfigXMLEnable{Path: []string{section, "config", "enable"}, Loose: true},
+		)
+	}
+	for _, section := range sections {
+		out = append(out,
+			LegacyListEnable{Path: []string{"installedpackages", section, "config"}, Loose: true},
+			LegacyListEnable{Path: []string{section, "config"}, Loose: true},
+		)
+	}
+	return out
+}
+
+func phpEnableExpr(section, key string) string {
+	return `$cfg=$config["installedpackages"]["` + section + `"]["config"][0] ?? []; $val=$cfg["` + key + `"] ?? ""; echo ($val === "on" || $val === "true" || $val === "1" || $val === true || $val === 1) ? "1" : "0";`
+}
+
+const accessPHPEnableExpr = `$raw=null;
+if (isset($config["installedpackages"]["zidaccess"]["config"])) { $raw=$config["installedpackages"]["zidaccess"]["config"]; }
+elseif (isset($config["installedpackages"]["zid-access"]["config"])) { $raw=$config["installedpackages"]["zid-access"]["config"]; }
+elseif (isset($config["installedpackages"]["zid_access"]["config"])) { $raw=$config["installedpackages"]["zid_access"]["config"]; }
+$val="";
+// Formato quebrado (legado): lista escalar sem chaves. Primeiro item costuma ser o enable.
+if (is_array($raw) && isset($raw[0]) && !is_array($raw[0])) {
+  $val=$raw[0];
+} elseif (!is_array($raw) && $raw !== null) {
+  $val=$raw;
+} else {
+  $item=$raw;
+  if (is_array($raw) && isset($raw[0]) && is_array($raw[0])) { $item=$raw[0]; }
+  elseif (is_array($raw) && isset($raw["item"])) {
+    $i=$raw["item"];
+    if (is_array($i) && isset($i[0]) && is_array($i[0])) { $item=$i[0]; }
+    elseif (is_array($i)) { $item=$i; }
+  }
+  if (is_array($item)) {
+    if (array_key_exists("enable", $item)) { $val=$item["enable"]; }
+    elseif (array_key_exists("enabled", $item)) { $val=$item["enabled"]; }
+  }
+}
+echo ($val === "on" || $val === "true" || $val === "1" || $val === "yes" || $val === true || $val === 1) ? "1" : "0";`
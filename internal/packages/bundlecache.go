@@ -0,0 +1,189 @@
+package packages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"zid-packages/internal/version"
+)
+
+const (
+	bundleCacheDir = "/var/db/zid-packages/bundles"
+	// bundleCacheKeep e quantos bundles verificados ficam guardados por pacote
+	// para rollback.
+	bundleCacheKeep = 3
+)
+
+var unsafeVersionChars = regexp.MustCompile(`[^A-Za-z0-9._+,-]`)
+
+// CachedBundle: Version e a versao publicada (nome do arquivo) e Installed a
+// que VersionLocal leu depois da instalacao; os formatos podem diferir (ex.:
+// "1.4.2" no catalogo e "1.4.2_3" no pacote instalado).
+type CachedBundle struct {
+	Version   string
+	Installed string
+	Path      string
+	CachedAt  time.Time
+}
+
+// bundleMeta fica em "<bundle>.meta" ao lado do bundle.
+type bundleMeta struct {
+	Version   string `json:"version"`
+	Installed string `json:"installed,omitempty"`
+}
+
+// cacheInstalledBundle guarda o bundle (com a .sig) apos uma instalacao bem
+// sucedida e devolve a versao usada como nome. Falhas de cache so sao logadas:
+// a instalacao em si ja aconteceu.
+func cacheInstalledBundle(key, version, bundle string, sig []byte) string {
+	installed := VersionLocal(key)
+	if version == "" {
+		version = installed
+	}
+	if version == "" {
+		return ""
+	}
+	if err := storeBundle(bundleCacheDir, key, version, installed, bundle, sig, bundleCacheKeep); err != nil {
+		enableLogger.Error("falha ao guardar bundle em cache: " + key + " " + version + " err=" + err.Error())
+	}
+	return version
+}
+
+func storeBundle(root, key, version, installed, bundle string, sig []byte, keep int) error {
+	dir := filepath.Join(root, key)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, bundleFileName(version))
+	if filepath.Clean(bundle) != dest {
+		if err := copyFile(bundle, dest+".tmp"); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest+".sig", sig, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(dest+".tmp", dest); err != nil {
+			return err
+		}
+	}
+	meta, err := json.Marshal(bundleMeta{Version: version, Installed: installed})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest+".meta", meta, 0600); err != nil {
+		return err
+	}
+	now := time.Now()
+	_ = os.Chtimes(dest, now, now)
+	return pruneBundles(root, key, keep)
+}
+
+func bundleFileName(version string) string {
+	return unsafeVersionChars.ReplaceAllString(version, "_") + ".tar.gz"
+}
+
+// CachedBundles lista os bundles guardados do pacote, do mais recente para o
+// mais antigo.
+func CachedBundles(key string) ([]CachedBundle, error) {
+	return listBundles(bundleCacheDir, key)
+}
+
+func listBundles(root, key string) ([]CachedBundle, error) {
+	matches, err := filepath.Glob(filepath.Join(root, key, "*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CachedBundle, 0, len(matches))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		b := CachedBundle{
+			Version:  strings.TrimSuffix(filepath.Base(path), ".tar.gz"),
+			Path:     path,
+			CachedAt: info.ModTime(),
+		}
+		// Bundles guardados antes do .meta ficam so com o nome do arquivo.
+		if data, err := os.ReadFile(path + ".meta"); err == nil {
+			var meta bundleMeta
+			if json.Unmarshal(data, &meta) == nil && meta.Version != "" {
+				b.Version, b.Installed = meta.Version, meta.Installed
+			}
+		}
+		out = append(out, b)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].CachedAt.After(out[j].CachedAt)
+	})
+	return out, nil
+}
+
+func pruneBundles(root, key string, keep int) error {
+	bundles, err := listBundles(root, key)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(bundles); i++ {
+		_ = os.Remove(bundles[i].Path)
+		_ = os.Remove(bundles[i].Path + ".sig")
+		_ = os.Remove(bundles[i].Path + ".meta")
+	}
+	return nil
+}
+
+// selectRollback escolhe o bundle alvo: a versao pedida ou, sem --to, o mais
+// recente diferente da versao instalada.
+func selectRollback(bundles []CachedBundle, current, to string) (CachedBundle, error) {
+	if to != "" {
+		for _, b := range bundles {
+			if b.matches(to) {
+				return b, nil
+			}
+		}
+		return CachedBundle{}, fmt.Errorf("versao %s nao esta no cache de bundles", to)
+	}
+	for _, b := range bundles {
+		if current == "" || !b.matches(current) {
+			return b, nil
+		}
+	}
+	return CachedBundle{}, errors.New("nenhuma versao anterior no cache de bundles")
+}
+
+// matches compara v com a versao publicada e com a instalada do bundle.
+func (b CachedBundle) matches(v string) bool {
+	return sameVersion(b.Version, v) || (b.Installed != "" && sameVersion(b.Installed, v))
+}
+
+func sameVersion(a, b string) bool {
+	if c, ok := version.Compare(a, b); ok {
+		return c == 0
+	}
+	return bundleFileName(a) == bundleFileName(b)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
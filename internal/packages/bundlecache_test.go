@@ -0,0 +1,113 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreBundleKeepsNewest(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(src, []byte("bundle"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, v := range []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0"} {
+		if err := storeBundle(root, "zid-proxy", v, v, src, []byte("sig"), 3); err != nil {
+			t.Fatalf("storeBundle(%s): %v", v, err)
+		}
+		when := base.Add(time.Duration(i) * time.Minute)
+		_ = os.Chtimes(filepath.Join(root, "zid-proxy", bundleFileName(v)), when, when)
+	}
+	if err := pruneBundles(root, "zid-proxy", 3); err != nil {
+		t.Fatal(err)
+	}
+	got, err := listBundles(root, "zid-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.3.0", "1.2.0", "1.1.0"}
+	if len(got) != len(want) {
+		t.Fatalf("listBundles()=%d bundles; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Version != want[i] {
+			t.Fatalf("listBundles()[%d]=%q; want %q", i, got[i].Version, want[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "zid-proxy", "1.0.0.tar.gz.sig")); !os.IsNotExist(err) {
+		t.Fatalf("sig do bundle removido ainda existe: %v", err)
+	}
+}
+
+func TestSelectRollback(t *testing.T) {
+	bundles := []CachedBundle{{Version: "1.3.0"}, {Version: "1.2.0"}, {Version: "1.1.0"}}
+	cases := []struct {
+		current, to string
+		want        string
+		wantErr     bool
+	}{
+		{current: "1.3.0", want: "1.2.0"},
+		{current: "1.2.0", want: "1.3.0"},
+		{current: "", want: "1.3.0"},
+		{current: "1.3.0", to: "1.1.0", want: "1.1.0"},
+		{current: "1.3.0", to: "0.9.0", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := selectRollback(bundles, tc.current, tc.to)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("selectRollback(%q, %q)=%q; want erro", tc.current, tc.to, got.Version)
+			}
+			continue
+		}
+		if err != nil || got.Version != tc.want {
+			t.Fatalf("selectRollback(%q, %q)=%q, %v; want %q", tc.current, tc.to, got.Version, err, tc.want)
+		}
+	}
+	if _, err := selectRollback([]CachedBundle{{Version: "1.0"}}, "1.0", ""); err == nil {
+		t.Fatalf("selectRollback sem versao anterior; want erro")
+	}
+}
+
+func TestSelectRollback_LocalVersionFormatDiffers(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(src, []byte("bundle"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Catalogo publica "1.4.x"; o pacote instalado reporta revisao de port.
+	base := time.Now().Add(-time.Hour)
+	for i, v := range [][2]string{{"1.4.1", "1.4.1_1"}, {"1.4.2", "1.4.2_3"}} {
+		if err := storeBundle(root, "zid-proxy", v[0], v[1], src, []byte("sig"), 3); err != nil {
+			t.Fatal(err)
+		}
+		when := base.Add(time.Duration(i) * time.Minute)
+		_ = os.Chtimes(filepath.Join(root, "zid-proxy", bundleFileName(v[0])), when, when)
+	}
+	bundles, err := listBundles(root, "zid-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundles[0].Version != "1.4.2" || bundles[0].Installed != "1.4.2_3" {
+		t.Fatalf("listBundles()[0]=%#v; want metadata from .meta", bundles[0])
+	}
+	cases := []struct {
+		current, to string
+		want        string
+	}{
+		{current: "1.4.2_3", want: "1.4.1"},
+		{current: "v1.4.2", want: "1.4.1"},
+		{current: "1.4.1_1", want: "1.4.2"},
+		{current: "1.4.2_3", to: "1.4.1_1", want: "1.4.1"},
+		{current: "1.4.2_3", to: "1.4.1", want: "1.4.1"},
+	}
+	for _, tc := range cases {
+		got, err := selectRollback(bundles, tc.current, tc.to)
+		if err != nil || got.Version != tc.want {
+			t.Fatalf("selectRollback(%q, %q)=%q, %v; want %q", tc.current, tc.to, got.Version, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package packages
+
+import (
+	"fmt"
+
+	"zid-packages/internal/catalog"
+)
+
+// CatalogEntry devolve a release publicada no canal configurado do pacote.
+func CatalogEntry(key string) (catalog.Entry, bool) {
+	cat, err := catalog.Current()
+	if err != nil {
+		return catalog.Entry{}, false
+	}
+	return cat.LookupChannel(key, Channel(key))
+}
+
+// CatalogOnly lista pacotes publicados no catalogo que este binario ainda nao
+// conhece (sem descritor embutido nem em packages.d).
+func CatalogOnly() []catalog.Entry {
+	cat, err := catalog.Current()
+	if err != nil {
+		return nil
+	}
+	out := []catalog.Entry{}
+	for _, entry := range cat.Packages {
+		if _, ok := lookupPackage(entry.Key); !ok {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// RequiresZidPackages devolve a versao minima de zid-packages exigida pela
+// entrada do catalogo quando a instalada for mais antiga.
+func RequiresZidPackages(entry catalog.Entry) (string, bool) {
+	if entry.MinZidPackages == "" {
+		return "", false
+	}
+	if UpdateAvailableWith(VersionLocal("zid-packages"), entry.MinZidPackages) {
+		return entry.MinZidPackages, true
+	}
+	return "", false
+}
+
+type bundleSource struct {
+	URL     string
+	SHA256  string
+	Version string
+	Size    int64
+}
+
+// resolveBundle escolhe o bundle do canal do pacote: o do catalogo quando
+// publicado (com digest e versao), senao o do descritor.
+func resolveBundle(pkg Package) (bundleSource, error) {
+	entry, ok := CatalogEntry(pkg.Key)
+	if !ok || entry.BundleURL == "" {
+		urls, err := channelURLs(pkg, Channel(pkg.Key))
+		if err != nil {
+			return bundleSource{}, err
+		}
+		return bundleSource{URL: urls.BundleURL}, nil
+	}
+	if min, needed := RequiresZidPackages(entry); needed {
+		return bundleSource{}, fmt.Errorf("%s %s requer zid-packages >= %s", pkg.Key, entry.Version, min)
+	}
+	return bundleSource{URL: entry.BundleURL, SHA256: entry.SHA256, Version: entry.Version, Size: entry.Size}, nil
+}
@@ -0,0 +1,120 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"zid-packages/internal/catalog"
+)
+
+var channelsPath = "/var/db/zid-packages/channels.json"
+
+var channelNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+
+// ChannelURLs sobrescreve, no descritor, as URLs de um canal que nao segue o
+// padrao "<nome>-<canal>-latest.*".
+type ChannelURLs struct {
+	BundleURL  string `json:"bundle_url,omitempty"`
+	VersionURL string `json:"version_url,omitempty"`
+}
+
+// Channel devolve o canal configurado para o pacote (stable por padrao).
+func Channel(key string) string {
+	all, _ := loadChannels()
+	if ch, ok := all[key]; ok && ch != "" {
+		return ch
+	}
+	return catalog.ChannelStable
+}
+
+// SetChannel grava o canal do pacote; stable remove a configuracao.
+func SetChannel(key, channel string) error {
+	if _, err := Get(key); err != nil {
+		return err
+	}
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if !channelNameRe.MatchString(channel) {
+		return fmt.Errorf("canal invalido: %q", channel)
+	}
+	all, err := loadChannels()
+	if err != nil {
+		return err
+	}
+	if channel == catalog.ChannelStable {
+		delete(all, key)
+	} else {
+		all[key] = channel
+	}
+	return saveChannels(all)
+}
+
+func loadChannels() (map[string]string, error) {
+	out := map[string]string{}
+	data, err := os.ReadFile(channelsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]string{}, err
+	}
+	return out, nil
+}
+
+func saveChannels(all map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(channelsPath), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := channelsPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, channelsPath)
+}
+
+// channelURLs resolve bundle/version do descritor para o canal: o override de
+// Channels quando existir, senao "-latest." vira "-<canal>-latest.".
+func channelURLs(pkg Package, channel string) (ChannelURLs, error) {
+	urls := ChannelURLs{BundleURL: pkg.BundleURL, VersionURL: pkg.VersionURL}
+	if channel == "" || channel == catalog.ChannelStable {
+		return urls, nil
+	}
+	if override, ok := pkg.Channels[channel]; ok {
+		if override.BundleURL != "" {
+			urls.BundleURL = override.BundleURL
+		}
+		if override.VersionURL != "" {
+			urls.VersionURL = override.VersionURL
+		}
+		return urls, nil
+	}
+	var err error
+	if urls.BundleURL, err = channelURL(urls.BundleURL, channel); err != nil {
+		return ChannelURLs{}, err
+	}
+	if urls.VersionURL, err = channelURL(urls.VersionURL, channel); err != nil {
+		return ChannelURLs{}, err
+	}
+	return urls, nil
+}
+
+func channelURL(url, channel string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+	i := strings.LastIndex(url, "-latest.")
+	if i < 0 {
+		return "", fmt.Errorf("canal %s: url sem -latest: %s", channel, url)
+	}
+	return url[:i] + "-" + channel + url[i:], nil
+}
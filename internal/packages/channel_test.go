@@ -0,0 +1,42 @@
+package packages
+
+import "testing"
+
+func TestChannelURLs(t *testing.T) {
+	pkg := Package{
+		Key:        "zid-proxy",
+		BundleURL:  "https://s3.example/portal/zid-proxy-pfsense-latest.tar.gz",
+		VersionURL: "https://s3.example/portal/zid-proxy-pfsense-latest.version",
+		Channels: map[string]ChannelURLs{
+			"lts": {BundleURL: "https://s3.example/lts/zid-proxy-1.4.tar.gz"},
+		},
+	}
+	cases := []struct {
+		channel     string
+		wantBundle  string
+		wantVersion string
+	}{
+		{channel: "stable", wantBundle: pkg.BundleURL, wantVersion: pkg.VersionURL},
+		{channel: "", wantBundle: pkg.BundleURL, wantVersion: pkg.VersionURL},
+		{
+			channel:     "beta",
+			wantBundle:  "https://s3.example/portal/zid-proxy-pfsense-beta-latest.tar.gz",
+			wantVersion: "https://s3.example/portal/zid-proxy-pfsense-beta-latest.version",
+		},
+		{channel: "lts", wantBundle: "https://s3.example/lts/zid-proxy-1.4.tar.gz", wantVersion: pkg.VersionURL},
+	}
+	for _, tc := range cases {
+		got, err := channelURLs(pkg, tc.channel)
+		if err != nil {
+			t.Fatalf("channelURLs(%q) err=%v", tc.channel, err)
+		}
+		if got.BundleURL != tc.wantBundle || got.VersionURL != tc.wantVersion {
+			t.Fatalf("channelURLs(%q)=%+v; want %q, %q", tc.channel, got, tc.wantBundle, tc.wantVersion)
+		}
+	}
+
+	pkg.BundleURL = "https://s3.example/portal/zid-proxy.tar.gz"
+	if _, err := channelURLs(pkg, "beta"); err == nil {
+		t.Fatalf("channelURLs() sem -latest; want erro")
+	}
+}
@@ -0,0 +1,66 @@
+package packages
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"zid-packages/internal/s3"
+)
+
+var digestRe = regexp.MustCompile(`(?i)\b([0-9a-f]{64})\b`)
+
+// expectedDigest devolve o sha256 esperado do bundle: o do catalogo ou, na
+// falta dele, o publicado em "<bundle>.sha256".
+func expectedDigest(src bundleSource) (string, error) {
+	if src.SHA256 != "" {
+		return parseDigest(src.SHA256)
+	}
+	data, err := s3.FetchBytes(src.URL+".sha256", 4096)
+	if err != nil {
+		return "", fmt.Errorf("sha256 do bundle indisponivel: %w", err)
+	}
+	if err := s3.FetchAndVerify(src.URL+".sha256", data); err != nil {
+		return "", err
+	}
+	return parseDigest(string(data))
+}
+
+// parseDigest aceita o digest puro (sha256 -q), o formato do sha256sum
+// ("<hex>  arquivo") e o BSD ("SHA256 (arquivo) = <hex>").
+func parseDigest(text string) (string, error) {
+	match := digestRe.FindStringSubmatch(strings.TrimSpace(text))
+	if len(match) < 2 {
+		return "", errors.New("sha256 invalido")
+	}
+	return strings.ToLower(match[1]), nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func verifyFileSHA256(path, expected string) (string, error) {
+	got, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	if got != expected {
+		return got, fmt.Errorf("sha256 do bundle nao confere: esperado %s, obtido %s", expected, got)
+	}
+	return got, nil
+}
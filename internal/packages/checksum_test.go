@@ -0,0 +1,49 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDigest(t *testing.T) {
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: digest + "\n"},
+		{in: strings.ToUpper(digest)},
+		{in: digest + "  zid-proxy-pfsense-latest.tar.gz"},
+		{in: "SHA256 (zid-proxy-pfsense-latest.tar.gz) = " + digest},
+		{in: "not a digest", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseDigest(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("parseDigest(%q) should fail", tc.in)
+			}
+			continue
+		}
+		if err != nil || got != digest {
+			t.Fatalf("parseDigest(%q)=%q,%v; want %q", tc.in, got, err, digest)
+		}
+	}
+}
+
+func TestVerifyFileSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if _, err := verifyFileSHA256(path, digest); err != nil {
+		t.Fatalf("verifyFileSHA256() err=%v", err)
+	}
+	if _, err := verifyFileSHA256(path, strings.Repeat("0", 64)); err == nil {
+		t.Fatalf("verifyFileSHA256() should reject mismatched digest")
+	}
+}
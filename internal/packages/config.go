@@ -1,100 +1,33 @@
 package packages
 
 import (
-	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"os"
 	"strings"
-	"time"
 )
 
-const configXMLPath = "/conf/config.xml"
+var configXMLPath = "/conf/config.xml"
 
-func readConfigXMLValue(path []string) (string, bool) {
-	data, err := os.ReadFile(configXMLPath)
-	if err != nil {
-		return "", false
-	}
-	dec := xml.NewDecoder(bytes.NewReader(data))
-	stack := make([]string, 0, len(path))
-	for {
-		tok, err := dec.Token()
-		if err != nil {
-			return "", false
-		}
-		switch t := tok.(type) {
-		case xml.StartElement:
-			stack = append(stack, t.Name.Local)
-		case xml.EndElement:
-			if len(stack) > 0 {
-				stack = stack[:len(stack)-1]
-			}
-		case xml.CharData:
-			if matchesPath(stack, path) {
-				val := strings.TrimSpace(string(t))
-				if val != "" {
-					return val, true
-				}
-			}
-		}
-	}
+func readConfigXMLValue(path []string, loose bool) (string, bool) {
+	return readConfigXMLListItem(path, 0, loose)
 }
 
-func readConfigXMLValueRetry(path []string, attempts int) (string, bool) {
-	if attempts <= 0 {
-		attempts = 3
-	}
-	for i := 0; i < attempts; i++ {
-		if val, ok := readConfigXMLValue(path); ok {
-			return val, true
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
-	return "", false
-}
-
-func readConfigXMLValueLoose(path []string) (string, bool) {
-	data, err := os.ReadFile(configXMLPath)
-	if err != nil {
+// readConfigXMLListItem devolve o item index entre os valores escalares
+// (nao vazios) encontrados em path.
+func readConfigXMLListItem(path []string, index int, loose bool) (string, bool) {
+	cfg, ok := currentConfigXML()
+	if !ok {
 		return "", false
 	}
-	dec := xml.NewDecoder(bytes.NewReader(data))
-	stack := make([]string, 0, len(path))
-	for {
-		tok, err := dec.Token()
-		if err != nil {
-			return "", false
-		}
-		switch t := tok.(type) {
-		case xml.StartElement:
-			stack = append(stack, t.Name.Local)
-		case xml.EndElement:
-			if len(stack) > 0 {
-				stack = stack[:len(stack)-1]
-			}
-		case xml.CharData:
-			if matchesPathLoose(stack, path) {
-				val := strings.TrimSpace(string(t))
-				if val != "" {
-					return val, true
-				}
-			}
-		}
-	}
+	return cfg.item(path, index, loose)
 }
 
-func readConfigXMLValueLooseRetry(path []string, attempts int) (string, bool) {
-	if attempts <= 0 {
-		attempts = 3
-	}
-	for i := 0; i < attempts; i++ {
-		if val, ok := readConfigXMLValueLoose(path); ok {
-			return val, true
-		}
-		time.Sleep(50 * time.Millisecond)
+func readConfigXMLPackageVersion(pkgName string) string {
+	cfg, ok := currentConfigXML()
+	if !ok {
+		return ""
 	}
-	return "", false
+	return cfg.packageVersion(pkgName)
 }
 
 func matchesPath(stack, path []string) bool {
@@ -0,0 +1,220 @@
+package packages
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// O config.xml chega a varios MB e e consultado dezenas de vezes por ciclo
+// do watchdog (enable de cada pacote/servico, versoes). Ele e lido e
+// tokenizado uma vez e reaproveitado enquanto mtime/tamanho/inode nao mudarem.
+const (
+	configLoadAttempts = 3
+	configRetryDelay   = 50 * time.Millisecond
+)
+
+var (
+	configSnapshotMu sync.Mutex
+	configSnapshot   *configXML
+)
+
+type configFileID struct {
+	path    string
+	modTime time.Time
+	size    int64
+	inode   uint64
+}
+
+type configNode struct {
+	name     string
+	text     string
+	children []*configNode
+}
+
+// configText e um valor escalar (texto nao vazio) com o caminho completo do
+// elemento que o contem, na ordem do documento.
+type configText struct {
+	stack []string
+	value string
+}
+
+type configXML struct {
+	id     configFileID
+	root   *configNode
+	texts  []configText
+	byLeaf map[string][]int
+}
+
+func configFileIDOf(path string, info os.FileInfo) configFileID {
+	id := configFileID{path: path, modTime: info.ModTime(), size: info.Size()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		id.inode = uint64(st.Ino)
+	}
+	return id
+}
+
+// currentConfigXML devolve o snapshot do config.xml, relendo o arquivo so
+// quando ele mudou. Um XML que nao fecha (pfSense no meio do write_config)
+// e relido algumas vezes; se continuar incompleto, vale o que foi possivel
+// ler dele, sem guardar em cache.
+func currentConfigXML() (*configXML, bool) {
+	var partial *configXML
+	for attempt := 0; attempt < configLoadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(configRetryDelay)
+		}
+		path := configXMLPath
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		id := configFileIDOf(path, info)
+		configSnapshotMu.Lock()
+		cached := configSnapshot
+		configSnapshotMu.Unlock()
+		if cached != nil && cached.id == id {
+			return cached, true
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		snap, err := parseConfigXML(data)
+		snap.id = id
+		if err != nil {
+			partial = snap
+			continue
+		}
+		// Arquivo trocado durante a leitura: o conteudo pode nao bater com id.
+		if info, err := os.Stat(path); err != nil || configFileIDOf(path, info) != id {
+			partial = snap
+			continue
+		}
+		configSnapshotMu.Lock()
+		configSnapshot = snap
+		configSnapshotMu.Unlock()
+		return snap, true
+	}
+	return partial, partial != nil
+}
+
+func parseConfigXML(data []byte) (*configXML, error) {
+	snap := &configXML{root: &configNode{}, byLeaf: map[string][]int{}}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	nodes := []*configNode{snap.root}
+	var stack []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(stack) == 0 {
+				return snap, nil
+			}
+			return snap, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &configNode{name: t.Name.Local}
+			parent := nodes[len(nodes)-1]
+			parent.children = append(parent.children, node)
+			nodes = append(nodes, node)
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+				nodes = nodes[:len(nodes)-1]
+			}
+		case xml.CharData:
+			val := strings.TrimSpace(string(t))
+			if val == "" || len(stack) == 0 {
+				continue
+			}
+			node := nodes[len(nodes)-1]
+			if node.text == "" {
+				node.text = val
+			}
+			leaf := stack[len(stack)-1]
+			snap.byLeaf[leaf] = append(snap.byLeaf[leaf], len(snap.texts))
+			snap.texts = append(snap.texts, configText{stack: append([]string(nil), stack...), value: val})
+		}
+	}
+}
+
+// item devolve o valor index entre os textos cujo caminho casa com path
+// (sufixo contiguo ou, com loose, subsequencia).
+func (c *configXML) item(path []string, index int, loose bool) (string, bool) {
+	if len(path) == 0 || index < 0 {
+		return "", false
+	}
+	seen := 0
+	check := func(t configText) (string, bool) {
+		if seen == index {
+			return t.value, true
+		}
+		seen++
+		return "", false
+	}
+	if !loose {
+		// Match estrito termina sempre no ultimo elemento de path.
+		for _, i := range c.byLeaf[path[len(path)-1]] {
+			if matchesPath(c.texts[i].stack, path) {
+				if val, ok := check(c.texts[i]); ok {
+					return val, true
+				}
+			}
+		}
+		return "", false
+	}
+	for _, t := range c.texts {
+		if matchesPathLoose(t.stack, path) {
+			if val, ok := check(t); ok {
+				return val, true
+			}
+		}
+	}
+	return "", false
+}
+
+// packageVersion procura em installedpackages/package a entrada com o nome
+// informado.
+func (c *configXML) packageVersion(name string) string {
+	for _, node := range c.nodesAt([]string{"installedpackages", "package"}) {
+		if node.childText("name") == name {
+			if v := node.childText("version"); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func (c *configXML) nodesAt(path []string) []*configNode {
+	var out []*configNode
+	var walk func(node *configNode, stack []string)
+	walk = func(node *configNode, stack []string) {
+		for _, child := range node.children {
+			childStack := append(stack, child.name)
+			if matchesPath(childStack, path) {
+				out = append(out, child)
+			}
+			walk(child, childStack)
+		}
+	}
+	walk(c.root, nil)
+	return out
+}
+
+func (n *configNode) childText(name string) string {
+	for _, child := range n.children {
+		if child.name == name && child.text != "" {
+			return child.text
+		}
+	}
+	return ""
+}
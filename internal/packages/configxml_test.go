@@ -0,0 +1,91 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfigXML = `<?xml version="1.0"?>
+<pfsense>
+	<installedpackages>
+		<package><name>zid-logs</name><version>1.2.3</version></package>
+		<package><name>zid-access</name><version>0.9.0</version></package>
+		<zidproxy><config><enable>on</enable></config></zidproxy>
+		<zidaccess><config>on</config><config>8080</config></zidaccess>
+	</installedpackages>
+	<zidgeolocation><settings><config><enable>yes</enable></config></settings></zidgeolocation>
+</pfsense>`
+
+func writeTestConfigXML(t *testing.T, content string) string {
+	t.Helper()
+	old := configXMLPath
+	t.Cleanup(func() { configXMLPath = old })
+	configXMLPath = filepath.Join(t.TempDir(), "config.xml")
+	if err := os.WriteFile(configXMLPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configXMLPath
+}
+
+func TestReadConfigXMLValue(t *testing.T) {
+	writeTestConfigXML(t, testConfigXML)
+	tests := []struct {
+		path   []string
+		loose  bool
+		want   string
+		wantOK bool
+	}{
+		{path: []string{"installedpackages", "zidproxy", "config", "enable"}, want: "on", wantOK: true},
+		{path: []string{"zidproxy", "config", "enable"}, want: "on", wantOK: true},
+		{path: []string{"zidgeolocation", "config", "enable"}},
+		{path: []string{"zidgeolocation", "config", "enable"}, loose: true, want: "yes", wantOK: true},
+		{path: []string{"zidaccess", "config"}, want: "on", wantOK: true},
+		{path: []string{"missing"}, loose: true},
+	}
+	for _, tc := range tests {
+		got, ok := readConfigXMLValue(tc.path, tc.loose)
+		if got != tc.want || ok != tc.wantOK {
+			t.Fatalf("readConfigXMLValue(%v, %v)=%q,%v; want %q,%v", tc.path, tc.loose, got, ok, tc.want, tc.wantOK)
+		}
+	}
+	if got := readConfigXMLPackageVersion("zid-access"); got != "0.9.0" {
+		t.Fatalf("readConfigXMLPackageVersion()=%q; want 0.9.0", got)
+	}
+}
+
+func TestCurrentConfigXML_CachedUntilFileChanges(t *testing.T) {
+	path := writeTestConfigXML(t, testConfigXML)
+	first, ok := currentConfigXML()
+	if !ok {
+		t.Fatalf("currentConfigXML() failed")
+	}
+	if again, _ := currentConfigXML(); again != first {
+		t.Fatalf("currentConfigXML() should reuse the snapshot")
+	}
+	updated := `<pfsense><installedpackages><zidproxy><config><enable>off</enable></config></zidproxy></installedpackages></pfsense>`
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := readConfigXMLValue([]string{"zidproxy", "config", "enable"}, false); got != "off" {
+		t.Fatalf("readConfigXMLValue() after change=%q; want off", got)
+	}
+}
+
+func TestCurrentConfigXML_TruncatedKeepsPartialValues(t *testing.T) {
+	writeTestConfigXML(t, testConfigXML[:len(testConfigXML)/2])
+	if got := readConfigXMLPackageVersion("zid-logs"); got != "1.2.3" {
+		t.Fatalf("readConfigXMLPackageVersion()=%q; want 1.2.3 from partial file", got)
+	}
+	configSnapshotMu.Lock()
+	cached := configSnapshot
+	configSnapshotMu.Unlock()
+	if cached != nil && cached.id.path == configXMLPath {
+		t.Fatalf("truncated config.xml should not be cached")
+	}
+}
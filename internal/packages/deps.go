@@ -0,0 +1,141 @@
+package packages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selfKey e o proprio zid-packages: atualiza-lo pode reiniciar o daemon que
+// conduz a rodada, por isso fica sempre por ultimo na ordem de update.
+const selfKey = "zid-packages"
+
+// DependencyOrder ordena keys com as dependencias antes de quem depende delas.
+// Dependencias fora de keys (ex.: nao instaladas) nao entram no resultado mas
+// ainda ordenam o restante; empates mantem a ordem dos descritores.
+func DependencyOrder(keys []string) ([]string, error) {
+	pkgs := descriptors()
+	want := map[string]bool{}
+	for _, key := range keys {
+		want[key] = true
+	}
+	roots := []string{}
+	for _, pkg := range pkgs {
+		if want[pkg.Key] {
+			roots = append(roots, pkg.Key)
+		}
+	}
+	sorted, err := topoSort(pkgs, roots)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(keys))
+	for _, key := range sorted {
+		if want[key] {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// UpdateOrder devolve os pacotes na ordem de update: dependencias primeiro e
+// zid-packages por ultimo.
+func UpdateOrder(pkgs []Package) ([]Package, error) {
+	byKey := map[string]Package{}
+	keys := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		byKey[pkg.Key] = pkg
+		if pkg.Key != selfKey {
+			keys = append(keys, pkg.Key)
+		}
+	}
+	ordered, err := DependencyOrder(keys)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := byKey[selfKey]; ok {
+		ordered = append(ordered, selfKey)
+	}
+	out := make([]Package, 0, len(ordered))
+	for _, key := range ordered {
+		out = append(out, byKey[key])
+	}
+	return out, nil
+}
+
+// Prerequisites devolve as dependencias transitivas de key na ordem em que
+// devem ser instaladas (sem a propria key).
+func Prerequisites(key string) ([]string, error) {
+	sorted, err := topoSort(descriptors(), []string{key})
+	if err != nil {
+		return nil, err
+	}
+	return sorted[:len(sorted)-1], nil
+}
+
+// Dependents lista os pacotes instalados que declaram dependencia direta de key.
+func Dependents(key string) []string {
+	out := []string{}
+	for _, pkg := range descriptors() {
+		for _, dep := range pkg.Depends {
+			if dep == key && Installed(pkg.Key) {
+				out = append(out, pkg.Key)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ServiceDependencies devolve os servicos que precisam estar rodando antes de
+// iniciar key.
+func ServiceDependencies(key string) []string {
+	svc, _, ok := lookupService(key)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), svc.Depends...)
+}
+
+func topoSort(pkgs []Package, roots []string) ([]string, error) {
+	deps := map[string][]string{}
+	for _, pkg := range pkgs {
+		deps[pkg.Key] = pkg.Depends
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	out := []string{}
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependencia circular: %s", strings.Join(append(path, key), " -> "))
+		}
+		d, ok := deps[key]
+		if !ok {
+			if len(path) == 0 {
+				return fmt.Errorf("unknown package: %s", key)
+			}
+			return fmt.Errorf("%s depende de pacote desconhecido: %s", path[len(path)-1], key)
+		}
+		state[key] = visiting
+		for _, dep := range d {
+			if err := visit(dep, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = done
+		out = append(out, key)
+		return nil
+	}
+	for _, key := range roots {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
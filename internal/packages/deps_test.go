@@ -0,0 +1,60 @@
+package packages
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopoSort(t *testing.T) {
+	pkgs := []Package{
+		{Key: "a", Depends: []string{"c"}},
+		{Key: "b"},
+		{Key: "c", Depends: []string{"b"}},
+		{Key: "d", Depends: []string{"a", "b"}},
+	}
+	got, err := topoSort(pkgs, []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatalf("topoSort() err=%v", err)
+	}
+	if strings.Join(got, ",") != "b,c,a,d" {
+		t.Fatalf("topoSort()=%v; want [b c a d]", got)
+	}
+
+	got, err = topoSort(pkgs, []string{"d"})
+	if err != nil || strings.Join(got, ",") != "b,c,a,d" {
+		t.Fatalf("topoSort(d)=%v, %v; want [b c a d]", got, err)
+	}
+}
+
+func TestTopoSort_Errors(t *testing.T) {
+	cycle := []Package{
+		{Key: "a", Depends: []string{"b"}},
+		{Key: "b", Depends: []string{"a"}},
+	}
+	if _, err := topoSort(cycle, []string{"a"}); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("topoSort(ciclo) err=%v; want dependencia circular", err)
+	}
+	unknown := []Package{{Key: "a", Depends: []string{"x"}}}
+	if _, err := topoSort(unknown, []string{"a"}); err == nil {
+		t.Fatalf("topoSort(dependencia desconhecida) err=nil")
+	}
+}
+
+func TestUpdateOrder_Builtin(t *testing.T) {
+	ordered, err := UpdateOrder(All())
+	if err != nil {
+		t.Fatalf("UpdateOrder() err=%v", err)
+	}
+	pos := map[string]int{}
+	for i, pkg := range ordered {
+		pos[pkg.Key] = i
+	}
+	if pos[selfKey] != len(ordered)-1 {
+		t.Fatalf("UpdateOrder(): zid-packages na posicao %d; want ultima", pos[selfKey])
+	}
+	for _, dep := range []string{"zid-proxy", "zid-geolocation", "zid-logs", "zid-access"} {
+		if pos[dep] > pos["zid-orchestrator"] {
+			t.Fatalf("UpdateOrder(): %s depois de zid-orchestrator", dep)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DescriptorDir contem descritores extras (um JSON por pacote). Um descritor
+// com a mesma key de um pacote embutido sobrescreve apenas os campos presentes;
+// listas presentes (services, version, depends) substituem as embutidas.
+const DescriptorDir = "/usr/local/etc/zid-packages/packages.d"
+
+type Package struct {
+	Key               string                 `json:"key"`
+	Name              string                 `json:"name"`
+	BundleURL         string                 `json:"bundle_url,omitempty"`
+	VersionURL        string                 `json:"version_url,omitempty"`
+	Channels          map[string]ChannelURLs `json:"channels,omitempty"`
+	UpdateCommand     string                 `json:"update_command,omitempty"`
+	InstallScriptGlob string                 `json:"install_script_glob,omitempty"`
+	UninstallScript   string                 `json:"uninstall_script,omitempty"`
+	Binary            string                 `json:"binary,omitempty"`
+	HealthTimeout     int                    `json:"health_timeout,omitempty"`
+	Depends           []string               `json:"depends,omitempty"`
+	Enable            EnableChain            `json:"enable"`
+	Version           []VersionSource        `json:"version,omitempty"`
+	Services          []Service              `json:"services,omitempty"`
+}
+
+type Service struct {
+	Key        string         `json:"key"`
+	Name       string         `json:"name,omitempty"`
+	Binary     string         `json:"binary,omitempty"`
+	RCScript   string         `json:"rc_script,omitempty"`
+	StartVerb  string         `json:"start_verb,omitempty"`
+	StopVerb   string         `json:"stop_verb,omitempty"`
+	Pgrep      string         `json:"pgrep,omitempty"`
+	Controller string         `json:"controller,omitempty"`
+	Depends    []string       `json:"depends,omitempty"`
+	Enable     EnableChain    `json:"enable"`
+	PostStart  *PHPHook       `json:"post_start,omitempty"`
+	PostStop   *PHPHook       `json:"post_stop,omitempty"`
+	Health     *HealthProbe   `json:"health,omitempty"`
+	Restart    *RestartPolicy `json:"restart,omitempty"`
+	// Unsupervised deixa o servico fora do watchdog (ex.: o proprio daemon);
+	// ele continua no status.
+	Unsupervised bool `json:"unsupervised,omitempty"`
+}
+
+type PHPHook struct {
+	Include  string `json:"include"`
+	Function string `json:"function"`
+}
+
+const (
+	VersionKindConfigPackage = "config-package"
+	VersionKindBinary        = "binary"
+	VersionKindPackageXML    = "package-xml"
+	VersionKindFile          = "file"
+)
+
+type VersionSource struct {
+	Kind    string   `json:"kind"`
+	Names   []string `json:"names,omitempty"`
+	File    string   `json:"file,omitempty"`
+	Numeric bool     `json:"numeric,omitempty"`
+}
+
+var (
+	descriptorsMu     sync.Mutex
+	descriptorsLoaded []Package
+)
+
+func descriptors() []Package {
+	descriptorsMu.Lock()
+	defer descriptorsMu.Unlock()
+	if descriptorsLoaded == nil {
+		descriptorsLoaded = loadDescriptors(DescriptorDir)
+	}
+	return descriptorsLoaded
+}
+
+// ReloadDescriptors descarta os descritores carregados; a proxima consulta
+// relê o diretorio drop-in.
+func ReloadDescriptors() {
+	descriptorsMu.Lock()
+	descriptorsLoaded = nil
+	descriptorsMu.Unlock()
+}
+
+func loadDescriptors(dir string) []Package {
+	out := make([]Package, 0, len(builtin))
+	for _, pkg := range builtin {
+		out = append(out, clonePackage(pkg))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+	for _, file := range files {
+		merged, err := mergeDescriptorFile(out, file)
+		if err != nil {
+			enableLogger.Error("descritor invalido: " + file + " err=" + err.Error())
+			continue
+		}
+		out = merged
+	}
+	return out
+}
+
+func mergeDescriptorFile(pkgs []Package, file string) ([]Package, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	var key string
+	if raw, ok := probe["key"]; ok {
+		if err := json.Unmarshal(raw, &key); err != nil {
+			return nil, err
+		}
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("key vazio")
+	}
+	for i := range pkgs {
+		if pkgs[i].Key != key {
+			continue
+		}
+		pkg := clonePackage(pkgs[i])
+		// encoding/json decodifica listas sobre os itens existentes: um
+		// override de services/version herdaria, por posicao, os campos
+		// omitidos do embutido. Listas presentes substituem as embutidas.
+		if _, ok := probe["services"]; ok {
+			pkg.Services = nil
+		}
+		if _, ok := probe["version"]; ok {
+			pkg.Version = nil
+		}
+		if _, ok := probe["depends"]; ok {
+			pkg.Depends = nil
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, err
+		}
+		if err := validateDescriptor(pkg); err != nil {
+			return nil, err
+		}
+		pkgs[i] = pkg
+		return pkgs, nil
+	}
+	var pkg Package
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	pkg.Key = key
+	if err := validateDescriptor(pkg); err != nil {
+		return nil, err
+	}
+	return append(pkgs, pkg), nil
+}
+
+func validateDescriptor(pkg Package) error {
+	if pkg.Name == "" {
+		return fmt.Errorf("%s: name vazio", pkg.Key)
+	}
+	seen := map[string]bool{}
+	for _, svc := range pkg.Services {
+		if svc.Key == "" {
+			return fmt.Errorf("%s: service sem key", pkg.Key)
+		}
+		if seen[svc.Key] {
+			return fmt.Errorf("%s: service duplicado: %s", pkg.Key, svc.Key)
+		}
+		seen[svc.Key] = true
+		if svc.Controller == "" && (svc.RCScript == "" || svc.Pgrep == "") {
+			return fmt.Errorf("%s: service %s sem rc_script/pgrep", pkg.Key, svc.Key)
+		}
+	}
+	for _, svc := range pkg.Services {
+		for _, dep := range svc.Depends {
+			if dep == svc.Key {
+				return fmt.Errorf("%s: service %s depende de si mesmo", pkg.Key, svc.Key)
+			}
+		}
+	}
+	for _, dep := range pkg.Depends {
+		if dep == pkg.Key {
+			return fmt.Errorf("%s: pacote depende de si mesmo", pkg.Key)
+		}
+	}
+	return nil
+}
+
+func clonePackage(pkg Package) Package {
+	out := pkg
+	out.Enable = cloneEnableChain(pkg.Enable)
+	out.Version = append([]VersionSource(nil), pkg.Version...)
+	out.Depends = append([]string(nil), pkg.Depends...)
+	if pkg.Channels != nil {
+		out.Channels = make(map[string]ChannelURLs, len(pkg.Channels))
+		for name, urls := range pkg.Channels {
+			out.Channels[name] = urls
+		}
+	}
+	out.Services = make([]Service, len(pkg.Services))
+	for i, svc := range pkg.Services {
+		svc.Enable = cloneEnableChain(svc.Enable)
+		svc.Depends = append([]string(nil), svc.Depends...)
+		out.Services[i] = svc
+	}
+	return out
+}
+
+func cloneEnableChain(chain EnableChain) EnableChain {
+	chain.Sources = append([]EnableSource(nil), chain.Sources...)
+	return chain
+}
+
+func lookupPackage(key string) (Package, bool) {
+	for _, pkg := range descriptors() {
+		if pkg.Key == key {
+			return pkg, true
+		}
+	}
+	return Package{}, false
+}
+
+// lookupService procura o servico em todos os pacotes e devolve o pacote pai.
+func lookupService(key string) (Service, Package, bool) {
+	for _, pkg := range descriptors() {
+		for _, svc := range pkg.Services {
+			if svc.Key == key {
+				return svc, pkg, true
+			}
+		}
+	}
+	return Service{}, Package{}, false
+}
+
+// lookupEnableChain resolve a cadeia de enable de um pacote ou, se a key for
+// de um servico com cadeia propria (ex.: zid-threatd), a do servico.
+func lookupEnableChain(key string) (EnableChain, bool) {
+	if pkg, ok := lookupPackage(key); ok {
+		return pkg.Enable, true
+	}
+	if svc, _, ok := lookupService(key); ok && len(svc.Enable.Sources) > 0 {
+		return svc.Enable, true
+	}
+	return EnableChain{}, false
+}
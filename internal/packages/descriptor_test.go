@@ -0,0 +1,96 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDescriptors_OverridesBuiltinFields(t *testing.T) {
+	dir := t.TempDir()
+	override := `{"key":"zid-logs","bundle_url":"https://example.invalid/zid-logs.tar.gz"}`
+	if err := os.WriteFile(filepath.Join(dir, "zid-logs.json"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs := loadDescriptors(dir)
+	if len(pkgs) != len(builtin) {
+		t.Fatalf("loadDescriptors() len=%d; want %d", len(pkgs), len(builtin))
+	}
+	for _, pkg := range pkgs {
+		if pkg.Key != "zid-logs" {
+			continue
+		}
+		if pkg.BundleURL != "https://example.invalid/zid-logs.tar.gz" {
+			t.Fatalf("BundleURL=%q; want override", pkg.BundleURL)
+		}
+		if pkg.Name != "ZID Logs" || len(pkg.Services) != 1 {
+			t.Fatalf("override should keep builtin fields: %#v", pkg)
+		}
+	}
+}
+
+func TestLoadDescriptors_OverrideReplacesLists(t *testing.T) {
+	dir := t.TempDir()
+	override := `{"key":"zid-logs",
+		"services":[{"key":"zid-logs","rc_script":"/usr/local/etc/rc.d/zid_logs_v2","pgrep":"^/usr/local/sbin/zid-logs2"}],
+		"version":[{"kind":"file","file":"/usr/local/share/zid-logs/VERSION"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "zid-logs.json"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range loadDescriptors(dir) {
+		if pkg.Key != "zid-logs" {
+			continue
+		}
+		if len(pkg.Services) != 1 {
+			t.Fatalf("Services=%#v; want only the override", pkg.Services)
+		}
+		svc := pkg.Services[0]
+		if svc.RCScript != "/usr/local/etc/rc.d/zid_logs_v2" || svc.StartVerb != "" || svc.StopVerb != "" {
+			t.Fatalf("Services[0]=%#v; want override without builtin start/stop verbs", svc)
+		}
+		if len(pkg.Version) != 1 || pkg.Version[0].Kind != VersionKindFile || pkg.Version[0].Names != nil {
+			t.Fatalf("Version=%#v; want only the override", pkg.Version)
+		}
+		if len(pkg.Enable.Sources) == 0 || pkg.Name != "ZID Logs" {
+			t.Fatalf("override should keep fields it omits: %#v", pkg)
+		}
+	}
+	for _, pkg := range builtin {
+		if pkg.Key == "zid-logs" && pkg.Services[0].StartVerb != "onestart" {
+			t.Fatalf("builtin changed by override: %#v", pkg.Services[0])
+		}
+	}
+}
+
+func TestLoadDescriptors_AddsNewPackage(t *testing.T) {
+	dir := t.TempDir()
+	desc := `{
+		"key": "zid-example",
+		"name": "ZID Example",
+		"binary": "/usr/local/sbin/zid-example",
+		"enable": {"sources": [{"kind": "rc.conf", "file": "/etc/rc.conf.local", "key": "zid_example_enable"}]},
+		"services": [{"key": "zid-example", "rc_script": "/usr/local/etc/rc.d/zid_example", "pgrep": "^/usr/local/sbin/zid-example"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "zid-example.json"), []byte(desc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs := loadDescriptors(dir)
+	last := pkgs[len(pkgs)-1]
+	if last.Key != "zid-example" || len(last.Services) != 1 {
+		t.Fatalf("loadDescriptors() last=%#v; want zid-example", last)
+	}
+	if got := last.Enable.Sources[0].Label(); got != "rc.conf.local:zid_example_enable" {
+		t.Fatalf("Label()=%q", got)
+	}
+}
+
+func TestLoadDescriptors_SkipsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	bad := `{"key":"zid-broken","name":"Broken","services":[{"key":"zid-broken"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadDescriptors(dir); len(got) != len(builtin) {
+		t.Fatalf("invalid descriptor should be skipped, len=%d", len(got))
+	}
+}
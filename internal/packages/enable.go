@@ -0,0 +1,272 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EnableSource e uma fonte da cadeia de enable. Read devolve o valor bruto
+// (interpretado por isOn) e ok=false quando a fonte nao responde: arquivo
+// ausente, chave inexistente ou config.xml em escrita. Files lista os
+// arquivos lidos, observados pelo daemon para reagir a mudancas.
+type EnableSource interface {
+	Label() string
+	Read() (string, bool)
+	Files() []string
+}
+
+// EnableChain lista as fontes consultadas, em ordem, para decidir se um
+// pacote/servico esta habilitado. Com Cache, o ultimo valor lido fica valido
+// por enabledCacheTTL quando nenhuma fonte responde (config.xml em escrita).
+type EnableChain struct {
+	Cache   bool
+	Sources []EnableSource
+}
+
+// PHPEnable avalia Expr no PHP do pfSense (com config.inc carregado); a
+// expressao imprime "1" ou "0".
+type PHPEnable struct {
+	Name string
+	Expr string
+}
+
+func (s PHPEnable) Label() string {
+	if s.Name != "" {
+		return "php:" + s.Name
+	}
+	return EnableKindPHP
+}
+
+func (s PHPEnable) Files() []string {
+	return []string{configXMLPath}
+}
+
+func (s PHPEnable) Read() (string, bool) {
+	b, ok := readEnableViaPHP(s.Expr)
+	if !ok {
+		return "", false
+	}
+	return boolString(b), true
+}
+
+// ConfigXMLEnable le o primeiro valor em Path no config.xml: sufixo contiguo
+// da arvore ou, com Loose, subsequencia.
+type ConfigXMLEnable struct {
+	Path  []string
+	Loose bool
+}
+
+func (s ConfigXMLEnable) Label() string {
+	return s.kind() + ":" + strings.Join(s.Path, "/")
+}
+
+func (s ConfigXMLEnable) kind() string {
+	if s.Loose {
+		return EnableKindConfigLoose
+	}
+	return EnableKindConfig
+}
+
+func (s ConfigXMLEnable) Files() []string {
+	return []string{configXMLPath}
+}
+
+func (s ConfigXMLEnable) Read() (string, bool) {
+	return readConfigXMLValue(s.Path, s.Loose)
+}
+
+// LegacyListEnable cobre o formato quebrado do config.xml em que a secao
+// virou lista escalar sem chaves (<config>valor</config> repetido): le o
+// item Index dessa lista.
+type LegacyListEnable struct {
+	Path  []string
+	Index int
+	Loose bool
+}
+
+func (s LegacyListEnable) Label() string {
+	kind := EnableKindConfigList
+	if s.Loose {
+		kind = EnableKindConfigListLoose
+	}
+	return kind + "[" + strconv.Itoa(s.Index) + "]:" + strings.Join(s.Path, "/")
+}
+
+func (s LegacyListEnable) Files() []string {
+	return []string{configXMLPath}
+}
+
+func (s LegacyListEnable) Read() (string, bool) {
+	return readConfigXMLListItem(s.Path, s.Index, s.Loose)
+}
+
+// JSONEnable le um booleano de um arquivo JSON do pacote.
+type JSONEnable struct {
+	File string
+	Key  string
+}
+
+func (s JSONEnable) Label() string {
+	return "config-json:" + s.File
+}
+
+func (s JSONEnable) Files() []string {
+	return []string{s.File}
+}
+
+func (s JSONEnable) Read() (string, bool) {
+	b, ok := readJSONBool(s.File, s.Key)
+	if !ok {
+		return "", false
+	}
+	return boolString(b), true
+}
+
+// RCEnable le uma variavel YES/NO como o rc.subr do FreeBSD: rc.conf,
+// rc.conf.local e rc.conf.d/<Name>, valendo a ultima atribuicao.
+type RCEnable struct {
+	Name string
+	Key  string
+}
+
+func (s RCEnable) Label() string {
+	return "rc:" + s.Key
+}
+
+func (s RCEnable) Files() []string {
+	return rcConfFiles(s.Name)
+}
+
+func (s RCEnable) Read() (string, bool) {
+	val, _, ok := lookupRCConf(s.Files(), s.Key)
+	if !ok {
+		return "", false
+	}
+	return boolString(isOn(val)), true
+}
+
+// RCConfEnable le uma variavel YES/NO de um unico arquivo rc.conf.
+type RCConfEnable struct {
+	File string
+	Key  string
+}
+
+func (s RCConfEnable) Label() string {
+	return filepath.Base(s.File) + ":" + s.Key
+}
+
+func (s RCConfEnable) Files() []string {
+	return []string{s.File}
+}
+
+func (s RCConfEnable) Read() (string, bool) {
+	val, _, ok := lookupRCConf([]string{s.File}, s.Key)
+	if !ok {
+		return "", false
+	}
+	return boolString(isOn(val)), true
+}
+
+// labeledEnable troca o label de uma fonte declarada com "label" no descritor.
+type labeledEnable struct {
+	EnableSource
+	label string
+}
+
+func (s labeledEnable) Label() string {
+	return s.label
+}
+
+const (
+	EnableKindPHP             = "php"
+	EnableKindConfig          = "config"
+	EnableKindConfigLoose     = "config-loose"
+	EnableKindConfigList      = "config-list"
+	EnableKindConfigListLoose = "config-list-loose"
+	EnableKindJSON            = "json"
+	EnableKindRCConf          = "rc.conf"
+	EnableKindRC              = "rc"
+)
+
+// enableSourceSpec e a forma de uma fonte no descritor JSON.
+type enableSourceSpec struct {
+	Kind  string   `json:"kind"`
+	Label string   `json:"label,omitempty"`
+	Path  []string `json:"path,omitempty"`
+	Index int      `json:"index,omitempty"`
+	File  string   `json:"file,omitempty"`
+	Key   string   `json:"key,omitempty"`
+	Name  string   `json:"name,omitempty"`
+	Expr  string   `json:"expr,omitempty"`
+}
+
+// UnmarshalJSON mantem os campos ausentes, como no resto do descritor: um
+// override so com "cache" preserva as fontes embutidas.
+func (c *EnableChain) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Cache   bool               `json:"cache"`
+		Sources []enableSourceSpec `json:"sources"`
+	}{Cache: c.Cache}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Cache = raw.Cache
+	if raw.Sources == nil {
+		return nil
+	}
+	sources := make([]EnableSource, 0, len(raw.Sources))
+	for _, spec := range raw.Sources {
+		src, err := spec.source()
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	c.Sources = sources
+	return nil
+}
+
+func (spec enableSourceSpec) source() (EnableSource, error) {
+	var src EnableSource
+	switch spec.Kind {
+	case EnableKindPHP:
+		if spec.Expr == "" {
+			return nil, fmt.Errorf("enable php sem expr")
+		}
+		src = PHPEnable{Name: spec.Name, Expr: spec.Expr}
+	case EnableKindConfig, EnableKindConfigLoose:
+		if len(spec.Path) == 0 {
+			return nil, fmt.Errorf("enable %s sem path", spec.Kind)
+		}
+		src = ConfigXMLEnable{Path: spec.Path, Loose: spec.Kind == EnableKindConfigLoose}
+	case EnableKindConfigList, EnableKindConfigListLoose:
+		if len(spec.Path) == 0 || spec.Index < 0 {
+			return nil, fmt.Errorf("enable %s sem path/index", spec.Kind)
+		}
+		src = LegacyListEnable{Path: spec.Path, Index: spec.Index, Loose: spec.Kind == EnableKindConfigListLoose}
+	case EnableKindJSON:
+		if spec.File == "" || spec.Key == "" {
+			return nil, fmt.Errorf("enable %s sem file/key", spec.Kind)
+		}
+		src = JSONEnable{File: spec.File, Key: spec.Key}
+	case EnableKindRCConf:
+		if spec.File == "" || spec.Key == "" {
+			return nil, fmt.Errorf("enable %s sem file/key", spec.Kind)
+		}
+		src = RCConfEnable{File: spec.File, Key: spec.Key}
+	case EnableKindRC:
+		if spec.Key == "" {
+			return nil, fmt.Errorf("enable %s sem key", spec.Kind)
+		}
+		src = RCEnable{Name: spec.Name, Key: spec.Key}
+	default:
+		return nil, fmt.Errorf("enable kind desconhecido: %s", spec.Kind)
+	}
+	if spec.Label != "" {
+		return labeledEnable{EnableSource: src, label: spec.Label}, nil
+	}
+	return src, nil
+}
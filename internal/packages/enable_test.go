@@ -0,0 +1,103 @@
+package packages
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnableChainUnmarshal(t *testing.T) {
+	data := `{"cache": true, "sources": [
+		{"kind": "php", "label": "php:custom", "expr": "echo 1;"},
+		{"kind": "php", "name": "installedpackages/zidexample/config/enable", "expr": "echo 1;"},
+		{"kind": "config", "path": ["zidexample", "config", "enable"]},
+		{"kind": "config-loose", "path": ["zidexample", "enable"]},
+		{"kind": "config-list", "path": ["zidexample", "config"], "index": 1},
+		{"kind": "json", "file": "/usr/local/etc/zid-example/config.json", "key": "enabled"},
+		{"kind": "rc.conf", "file": "/etc/rc.conf.local", "key": "zid_example_enable"},
+		{"kind": "rc", "name": "zid_example", "key": "zid_example_enable"}
+	]}`
+	var chain EnableChain
+	if err := json.Unmarshal([]byte(data), &chain); err != nil {
+		t.Fatalf("Unmarshal() err=%v", err)
+	}
+	want := []string{
+		"php:custom",
+		"php:installedpackages/zidexample/config/enable",
+		"config:zidexample/config/enable",
+		"config-loose:zidexample/enable",
+		"config-list[1]:zidexample/config",
+		"config-json:/usr/local/etc/zid-example/config.json",
+		"rc.conf.local:zid_example_enable",
+		"rc:zid_example_enable",
+	}
+	if !chain.Cache || len(chain.Sources) != len(want) {
+		t.Fatalf("chain=%#v; want cache and %d sources", chain, len(want))
+	}
+	for i, src := range chain.Sources {
+		if got := src.Label(); got != want[i] {
+			t.Fatalf("Sources[%d].Label()=%q; want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestEnableChainUnmarshal_KeepsSourcesWhenAbsent(t *testing.T) {
+	chain := EnableChain{Sources: []EnableSource{
+		RCConfEnable{File: "/etc/rc.conf.local", Key: "zid_example_enable"},
+		RCConfEnable{File: "/etc/rc.conf", Key: "zid_example_enable"},
+	}}
+	if err := json.Unmarshal([]byte(`{"cache": true}`), &chain); err != nil {
+		t.Fatalf("Unmarshal() err=%v", err)
+	}
+	if !chain.Cache || len(chain.Sources) != 2 {
+		t.Fatalf("chain=%#v; want cache with builtin sources", chain)
+	}
+}
+
+func TestEnableChainUnmarshal_Invalid(t *testing.T) {
+	tests := []string{
+		`{"sources": [{"kind": "php"}]}`,
+		`{"sources": [{"kind": "config"}]}`,
+		`{"sources": [{"kind": "config-list", "path": ["x"], "index": -1}]}`,
+		`{"sources": [{"kind": "rc.conf", "file": "/etc/rc.conf"}]}`,
+		`{"sources": [{"kind": "rc", "name": "zid_example"}]}`,
+		`{"sources": [{"kind": "sysctl"}]}`,
+	}
+	for _, data := range tests {
+		var chain EnableChain
+		if err := json.Unmarshal([]byte(data), &chain); err == nil {
+			t.Fatalf("Unmarshal(%s) should fail", data)
+		}
+	}
+}
+
+func TestLegacyListEnableRead(t *testing.T) {
+	old := configXMLPath
+	defer func() { configXMLPath = old }()
+	configXMLPath = filepath.Join(t.TempDir(), "config.xml")
+	xml := `<pfsense><installedpackages><zidaccess>
+		<config>on</config>
+		<config>8080</config>
+	</zidaccess></installedpackages></pfsense>`
+	if err := os.WriteFile(configXMLPath, []byte(xml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := []string{"installedpackages", "zidaccess", "config"}
+	tests := []struct {
+		src    LegacyListEnable
+		want   string
+		wantOK bool
+	}{
+		{src: LegacyListEnable{Path: path}, want: "on", wantOK: true},
+		{src: LegacyListEnable{Path: path, Index: 1}, want: "8080", wantOK: true},
+		{src: LegacyListEnable{Path: path, Index: 2}},
+		{src: LegacyListEnable{Path: []string{"zidaccess", "config"}, Loose: true}, want: "on", wantOK: true},
+	}
+	for _, tc := range tests {
+		got, ok := tc.src.Read()
+		if got != tc.want || ok != tc.wantOK {
+			t.Fatalf("%s Read()=%q,%v; want %q,%v", tc.src.Label(), got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
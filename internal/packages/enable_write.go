@@ -0,0 +1,262 @@
+package packages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"zid-packages/internal/logx"
+)
+
+var ErrEnableReadOnly = errors.New("cadeia de enable sem fonte gravavel")
+
+// EnableWriter e implementado pelas fontes que sabem gravar o enable. So o
+// config.xml (via write_config do pfSense), o rc.conf.local/rc.conf.d e os
+// JSONs dos pacotes sao gravaveis: expressoes PHP, matchers loose e listas
+// legadas nao apontam para um lugar unico.
+type EnableWriter interface {
+	EnableSource
+	CanWrite() bool
+	WriteEnabled(enabled bool, reason string) error
+}
+
+func (s labeledEnable) unwrap() EnableSource {
+	return s.EnableSource
+}
+
+// SetEnabled grava o enable de um pacote ou servico na fonte que hoje decide
+// o valor (se for gravavel) ou na primeira fonte gravavel da cadeia. Devolve
+// a key da cadeia alterada e o label da fonte gravada.
+func SetEnabled(logger *logx.Logger, key string, enabled bool) (string, string, error) {
+	chainKey, ok := ServiceEnableKey(key)
+	if !ok {
+		return "", "", fmt.Errorf("unknown package or service: %s", key)
+	}
+	chain, _ := lookupEnableChain(chainKey)
+	target, ok := enableTarget(chain)
+	if !ok {
+		return chainKey, "", fmt.Errorf("%s: %w", chainKey, ErrEnableReadOnly)
+	}
+	pkgKey := chainKey
+	if _, pkg, ok := lookupService(chainKey); ok {
+		pkgKey = pkg.Key
+	}
+	unlock, err := lockPackage(pkgKey, "enable")
+	if err != nil {
+		return chainKey, target.Label(), err
+	}
+	defer unlock()
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	if err := target.WriteEnabled(enabled, "zid-packages: "+action+" "+key); err != nil {
+		return chainKey, target.Label(), err
+	}
+	logger.Info("service " + action + ": " + key + " via " + target.Label())
+	return chainKey, target.Label(), nil
+}
+
+// enableTarget prefere a fonte gravavel que respondeu (onde o valor atual
+// mora: ex. zid-access em "zid-access" em vez de "zidaccess"); sem nenhuma,
+// a primeira gravavel.
+func enableTarget(chain EnableChain) (EnableWriter, bool) {
+	var first EnableWriter
+	for _, src := range chain.Sources {
+		if l, ok := src.(labeledEnable); ok {
+			src = l.unwrap()
+		}
+		w, ok := src.(EnableWriter)
+		if !ok || !w.CanWrite() {
+			continue
+		}
+		if _, responded := w.Read(); responded {
+			return w, true
+		}
+		if first == nil {
+			first = w
+		}
+	}
+	return first, first != nil
+}
+
+// CanWrite aceita apenas o caminho completo sob installedpackages: e ele que
+// o write_config grava.
+func (s ConfigXMLEnable) CanWrite() bool {
+	return !s.Loose && len(s.Path) > 1 && s.Path[0] == "installedpackages"
+}
+
+func (s ConfigXMLEnable) WriteEnabled(enabled bool, reason string) error {
+	php := phpBin()
+	if php == "" {
+		return errors.New("php nao encontrado; config.xml so e alterado via write_config do pfSense")
+	}
+	out, err := exec.Command(php, "-r", configXMLSetScript(s.Path, enabled, reason)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("write_config falhou: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(out)), "ok") {
+		return fmt.Errorf("write_config falhou: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// configXMLSetScript altera $config e chama write_config(), que toma o lock
+// do config do pfSense e gera a revisao de backup. Listas (ex.: <config>
+// do pacote) sao acessadas pelo primeiro item, como nas telas do pacote.
+func configXMLSetScript(path []string, enabled bool, reason string) string {
+	data, _ := json.Marshal(path)
+	value := `unset($ref[$last]);`
+	if enabled {
+		value = `$ref[$last] = "on";`
+	}
+	return `require_once("config.inc");
+global $config;
+$path = json_decode(` + phpQuote(string(data)) + `, true);
+$last = array_pop($path);
+$ref = &$config;
+foreach ($path as $p) {
+  if (!isset($ref[$p]) || !is_array($ref[$p])) { $ref[$p] = array(); }
+  $ref = &$ref[$p];
+  if (isset($ref[0]) && is_array($ref[0])) { $ref = &$ref[0]; }
+}
+` + value + `
+write_config(` + phpQuote(reason) + `);
+echo "ok";`
+}
+
+func phpQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// CanWrite: rc.conf e do sistema; ajustes locais vao no rc.conf.local.
+func (s RCConfEnable) CanWrite() bool {
+	return filepath.Base(s.File) == "rc.conf.local"
+}
+
+func (s RCConfEnable) WriteEnabled(enabled bool, reason string) error {
+	value := "NO"
+	if enabled {
+		value = "YES"
+	}
+	data, err := os.ReadFile(s.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return replaceFile(s.File, []byte(setRCConfVar(string(data), s.Key, value)))
+}
+
+// setRCConfVar troca todas as atribuicoes de key (inclusive "export key=")
+// pela nova ou acrescenta no fim.
+func setRCConfVar(content, key, value string) string {
+	line := key + `="` + value + `"`
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines)+1)
+	replaced := false
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "export "))
+		if strings.HasPrefix(trimmed, key+"=") {
+			if !replaced {
+				out = append(out, line)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, l)
+	}
+	if !replaced {
+		if n := len(out); n > 0 && out[n-1] == "" {
+			out = out[:n-1]
+		}
+		out = append(out, line, "")
+	}
+	return strings.Join(out, "\n")
+}
+
+func (s RCEnable) CanWrite() bool {
+	return true
+}
+
+// WriteEnabled grava no arquivo que hoje define a variavel, para que a
+// alteracao venca a precedencia; se ela vem do rc.conf (do sistema) ou de
+// lugar nenhum, no rc.conf.local.
+func (s RCEnable) WriteEnabled(enabled bool, reason string) error {
+	file := filepath.Join(rcConfDir, "rc.conf.local")
+	if _, from, ok := lookupRCConf(s.Files(), s.Key); ok && from != filepath.Join(rcConfDir, "rc.conf") {
+		file = from
+	}
+	return RCConfEnable{File: file, Key: s.Key}.WriteEnabled(enabled, reason)
+}
+
+func (s JSONEnable) CanWrite() bool {
+	return true
+}
+
+func (s JSONEnable) WriteEnabled(enabled bool, reason string) error {
+	obj := map[string]interface{}{}
+	data, err := os.ReadFile(s.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return fmt.Errorf("%s: %w", s.File, err)
+		}
+	}
+	obj[s.Key] = enabled
+	out, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(s.File, append(out, '\n'))
+}
+
+// replaceFile grava de forma atomica (temporario + rename no mesmo
+// diretorio), mantendo permissoes e deixando o conteudo anterior em .bak.
+func replaceFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if err := copyFile(path, backupPath(path)); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupPath e <arquivo>.bak; dentro de rc.conf.d/<name>/ o backup fica oculto,
+// senao o rc.subr carregaria tambem o valor antigo.
+func backupPath(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(filepath.Dir(dir)) == "rc.conf.d" {
+		return filepath.Join(dir, "."+filepath.Base(path)+".bak")
+	}
+	return path + ".bak"
+}
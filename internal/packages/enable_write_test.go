@@ -0,0 +1,126 @@
+package packages
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetRCConfVar(t *testing.T) {
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{"", "zid_example_enable=\"YES\"\n"},
+		{"other=\"1\"\n", "other=\"1\"\nzid_example_enable=\"YES\"\n"},
+		{"zid_example_enable=\"NO\"\nother=\"1\"\n", "zid_example_enable=\"YES\"\nother=\"1\"\n"},
+		{"export zid_example_enable=NO\nzid_example_enable=\"NO\"\n", "zid_example_enable=\"YES\"\n"},
+		{"# zid_example_enable=\"NO\"\n", "# zid_example_enable=\"NO\"\nzid_example_enable=\"YES\"\n"},
+		{"zid_example_enable_extra=\"NO\"\n", "zid_example_enable_extra=\"NO\"\nzid_example_enable=\"YES\"\n"},
+	}
+	for _, c := range cases {
+		if got := setRCConfVar(c.content, "zid_example_enable", "YES"); got != c.want {
+			t.Fatalf("setRCConfVar(%q)=%q; want %q", c.content, got, c.want)
+		}
+	}
+}
+
+func TestRCConfEnableWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rc.conf.local")
+	if err := os.WriteFile(path, []byte("zid_example_enable=\"YES\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src := RCConfEnable{File: path, Key: "zid_example_enable"}
+	if !src.CanWrite() {
+		t.Fatalf("CanWrite()=false; want true")
+	}
+	if err := src.WriteEnabled(false, "test"); err != nil {
+		t.Fatalf("WriteEnabled() err=%v", err)
+	}
+	if val, ok := src.Read(); !ok || isOn(val) {
+		t.Fatalf("Read()=%q,%t; want false", val, ok)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Stat()=%v,%v; want mode 0600", info, err)
+	}
+	bak, err := os.ReadFile(path + ".bak")
+	if err != nil || string(bak) != "zid_example_enable=\"YES\"\n" {
+		t.Fatalf("backup=%q,%v; want previous content", bak, err)
+	}
+	if (RCConfEnable{File: "/etc/rc.conf", Key: "x"}).CanWrite() {
+		t.Fatalf("CanWrite(rc.conf)=true; want false")
+	}
+}
+
+func TestJSONEnableWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"enabled": false, "port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := JSONEnable{File: path, Key: "enabled"}
+	if err := src.WriteEnabled(true, "test"); err != nil {
+		t.Fatalf("WriteEnabled() err=%v", err)
+	}
+	data, _ := os.ReadFile(path)
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		t.Fatalf("Unmarshal(%q) err=%v", data, err)
+	}
+	if obj["enabled"] != true || obj["port"] != float64(8080) {
+		t.Fatalf("config=%v; want enabled=true and port kept", obj)
+	}
+}
+
+func TestEnableTarget(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "rc.conf.local")
+	present := filepath.Join(dir, "present.json")
+	if err := os.WriteFile(present, []byte(`{"enabled": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		chain EnableChain
+		want  string
+		ok    bool
+	}{
+		{EnableChain{Sources: []EnableSource{PHPEnable{Expr: "echo 1;"}}}, "", false},
+		{EnableChain{Sources: []EnableSource{
+			PHPEnable{Expr: "echo 1;"},
+			RCConfEnable{File: "/etc/rc.conf", Key: "x_enable"},
+			RCConfEnable{File: local, Key: "x_enable"},
+		}}, "rc.conf.local:x_enable", true},
+		{EnableChain{Sources: []EnableSource{
+			RCConfEnable{File: local, Key: "x_enable"},
+			labeledEnable{EnableSource: JSONEnable{File: present, Key: "enabled"}, label: "custom"},
+		}}, "config-json:" + present, true},
+		{EnableChain{Sources: []EnableSource{
+			ConfigXMLEnable{Path: []string{"zidexample", "enable"}, Loose: true},
+			ConfigXMLEnable{Path: []string{"zidexample", "config", "enable"}},
+		}}, "", false},
+	}
+	for i, c := range cases {
+		got, ok := enableTarget(c.chain)
+		if ok != c.ok || (ok && got.Label() != c.want) {
+			t.Fatalf("case %d: enableTarget()=%v,%t; want %q,%t", i, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestConfigXMLSetScript(t *testing.T) {
+	script := configXMLSetScript([]string{"installedpackages", "zidexample", "config", "enable"}, true, "zid-packages: enable it's")
+	for _, want := range []string{
+		`json_decode('["installedpackages","zidexample","config","enable"]', true)`,
+		`$ref[$last] = "on";`,
+		`write_config('zid-packages: enable it\'s');`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("configXMLSetScript() missing %q in:\n%s", want, script)
+		}
+	}
+	if script := configXMLSetScript([]string{"installedpackages", "x", "enable"}, false, "r"); !strings.Contains(script, `unset($ref[$last]);`) {
+		t.Fatalf("configXMLSetScript(disable) missing unset:\n%s", script)
+	}
+}
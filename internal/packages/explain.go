@@ -0,0 +1,63 @@
+package packages
+
+import "errors"
+
+const (
+	EnableSourceDefault = "default"
+	EnableSourceCache   = "cache"
+)
+
+// EnableSourceResult e o que uma fonte da cadeia devolveu. Consulted=false
+// marca fontes depois da vencedora: lidas so para o diagnostico.
+type EnableSourceResult struct {
+	Label     string `json:"label"`
+	Value     string `json:"value"`
+	Responded bool   `json:"responded"`
+	Enabled   bool   `json:"enabled"`
+	Consulted bool   `json:"consulted"`
+	Winner    bool   `json:"winner"`
+}
+
+// EnableExplanation descreve como o enable de Key foi decidido. Chain e a
+// key cuja cadeia foi usada (o pacote pai para servicos sem cadeia propria).
+// Source e o label da fonte vencedora, "cache" ou "default" (nenhuma fonte
+// respondeu: desabilitado).
+type EnableExplanation struct {
+	Key             string               `json:"key"`
+	Chain           string               `json:"chain"`
+	Origin          string               `json:"origin,omitempty"`
+	Enabled         bool                 `json:"enabled"`
+	Source          string               `json:"source"`
+	CacheEnabled    bool                 `json:"cache_enabled"`
+	CacheHit        bool                 `json:"cache_hit"`
+	CacheValue      bool                 `json:"cache_value"`
+	CacheAgeSeconds int64                `json:"cache_age_seconds"`
+	CacheUsed       bool                 `json:"cache_used"`
+	Sources         []EnableSourceResult `json:"sources"`
+}
+
+// ExplainEnabled resolve o enable de um pacote ou servico mostrando todas as
+// fontes da cadeia. O cache consultado e o deste processo: no daemon e o
+// mesmo usado pelo watchdog.
+func ExplainEnabled(key string) (EnableExplanation, error) {
+	chainKey, ok := ServiceEnableKey(key)
+	if !ok {
+		return EnableExplanation{Key: key}, errors.New("unknown package or service: " + key)
+	}
+	exp, err := resolveEnabled(chainKey, true)
+	exp.Key = key
+	return exp, err
+}
+
+// ServiceEnableKey devolve a key cuja cadeia de enable decide key: o proprio
+// pacote, o servico com cadeia propria (ex.: zid-threatd) ou, para servicos
+// sem cadeia, o pacote pai.
+func ServiceEnableKey(key string) (string, bool) {
+	if _, ok := lookupEnableChain(key); ok {
+		return key, true
+	}
+	if _, pkg, ok := lookupService(key); ok {
+		return pkg.Key, true
+	}
+	return "", false
+}
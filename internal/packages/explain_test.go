@@ -0,0 +1,78 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setTestDescriptors(t *testing.T, pkgs []Package) {
+	t.Helper()
+	descriptorsMu.Lock()
+	old := descriptorsLoaded
+	descriptorsLoaded = pkgs
+	descriptorsMu.Unlock()
+	t.Cleanup(func() {
+		descriptorsMu.Lock()
+		descriptorsLoaded = old
+		descriptorsMu.Unlock()
+	})
+}
+
+func TestExplainEnabled(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "rc.conf.local")
+	base := filepath.Join(dir, "rc.conf")
+	if err := os.WriteFile(local, []byte("zid_example_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, []byte("zid_example_enable=\"NO\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setTestDescriptors(t, []Package{{
+		Key:  "zid-example",
+		Name: "ZID Example",
+		Enable: EnableChain{Cache: true, Sources: []EnableSource{
+			JSONEnable{File: filepath.Join(dir, "missing.json"), Key: "enabled"},
+			RCConfEnable{File: local, Key: "zid_example_enable"},
+			RCConfEnable{File: base, Key: "zid_example_enable"},
+		}},
+		Services: []Service{{Key: "zid-example-helper", RCScript: "/bin/true", Pgrep: "helper"}},
+	}})
+	enabledCacheMu.Lock()
+	delete(enabledCache, "zid-example")
+	enabledCacheMu.Unlock()
+
+	exp, err := ExplainEnabled("zid-example-helper")
+	if err != nil {
+		t.Fatalf("ExplainEnabled() err=%v", err)
+	}
+	if exp.Chain != "zid-example" || !exp.Enabled || exp.Source != "rc.conf.local:zid_example_enable" || exp.CacheUsed {
+		t.Fatalf("ExplainEnabled()=%+v; want enabled via rc.conf.local", exp)
+	}
+	want := []EnableSourceResult{
+		{Label: "config-json:" + filepath.Join(dir, "missing.json"), Consulted: true},
+		{Label: "rc.conf.local:zid_example_enable", Value: "true", Responded: true, Enabled: true, Consulted: true, Winner: true},
+		{Label: "rc.conf:zid_example_enable", Value: "false", Responded: true},
+	}
+	if len(exp.Sources) != len(want) {
+		t.Fatalf("Sources=%+v; want %d", exp.Sources, len(want))
+	}
+	for i := range want {
+		if exp.Sources[i] != want[i] {
+			t.Fatalf("Sources[%d]=%+v; want %+v", i, exp.Sources[i], want[i])
+		}
+	}
+
+	// Sem nenhuma fonte respondendo vale o ultimo valor do cache.
+	_ = os.Remove(local)
+	_ = os.Remove(base)
+	exp, _ = ExplainEnabled("zid-example")
+	if !exp.CacheHit || !exp.CacheUsed || exp.Source != EnableSourceCache || !exp.Enabled {
+		t.Fatalf("ExplainEnabled() sem fontes=%+v; want cache", exp)
+	}
+
+	if _, err := ExplainEnabled("zid-unknown"); err == nil {
+		t.Fatalf("ExplainEnabled(desconhecido) err=nil")
+	}
+}
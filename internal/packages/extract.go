@@ -0,0 +1,262 @@
+package packages
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	maxEntrySize   = 256 * 1024 * 1024
+	maxExtractSize = 1024 * 1024 * 1024
+)
+
+type ExtractedFile struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Size int64  `json:"size,omitempty"`
+	Mode string `json:"mode,omitempty"`
+	Link string `json:"link,omitempty"`
+}
+
+// extractTarGz extrai o bundle em root sem confiar no conteudo: recusa paths
+// absolutos, componentes "..", links que saem de root, devices/fifos e
+// entradas acima dos limites de tamanho.
+func extractTarGz(bundle, root string) ([]ExtractedFile, error) {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := []ExtractedFile{}
+	total := int64(0)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		name, err := cleanEntryName(hdr.Name)
+		if err != nil {
+			return files, err
+		}
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if _, err := resolveInside(root, filepath.Dir(target)); err != nil {
+			return files, fmt.Errorf("bundle: entrada escapa do diretorio de extracao: %s", name)
+		}
+		entry := ExtractedFile{Path: name, Mode: fmt.Sprintf("%04o", hdr.Mode&0o7777)}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.Type = "dir"
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			entry.Type = "file"
+			entry.Size = hdr.Size
+			if hdr.Size > maxEntrySize {
+				return files, fmt.Errorf("bundle: entrada muito grande: %s", name)
+			}
+			total += hdr.Size
+			if total > maxExtractSize {
+				return files, errors.New("bundle: conteudo excede tamanho maximo")
+			}
+			if err := writeEntry(tr, target, hdr); err != nil {
+				return files, err
+			}
+		case tar.TypeSymlink:
+			entry.Type = "symlink"
+			entry.Link = hdr.Linkname
+			if err := checkSymlink(root, target, hdr.Linkname); err != nil {
+				return files, fmt.Errorf("%w: %s -> %s", err, name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return files, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return files, err
+			}
+		case tar.TypeLink:
+			entry.Type = "hardlink"
+			entry.Link = hdr.Linkname
+			linkName, err := cleanEntryName(hdr.Linkname)
+			if err != nil || linkName == "" {
+				return files, fmt.Errorf("bundle: hardlink invalido: %s -> %s", name, hdr.Linkname)
+			}
+			source, err := resolveInside(root, filepath.Join(root, filepath.FromSlash(linkName)))
+			if err != nil {
+				return files, fmt.Errorf("bundle: hardlink fora do diretorio de extracao: %s -> %s", name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return files, err
+			}
+			if err := os.Link(source, target); err != nil {
+				return files, err
+			}
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			continue
+		default:
+			return files, fmt.Errorf("bundle: tipo de entrada nao permitido (%c): %s", hdr.Typeflag, name)
+		}
+		files = append(files, entry)
+	}
+}
+
+func cleanEntryName(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("bundle: path absoluto ou invalido: %s", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("bundle: path com '..': %s", name)
+		}
+	}
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+var errLinkEscapes = errors.New("bundle: link fora do diretorio de extracao")
+
+// checkSymlink resolve o alvo a partir do diretorio fisico do link,
+// componente a componente sobre o que ja foi extraido: um ".." depois de um
+// symlink sobe a partir do destino dele, e nao do texto do link.
+func checkSymlink(root, target, link string) error {
+	if link == "" || filepath.IsAbs(link) {
+		return errLinkEscapes
+	}
+	dir, err := resolveInside(root, filepath.Dir(target))
+	if err != nil {
+		return errLinkEscapes
+	}
+	if _, err := resolveLink(root, dir, link, 0); err != nil {
+		return errLinkEscapes
+	}
+	return nil
+}
+
+const maxLinkDepth = 40
+
+// resolveLink segue link (relativo a dir) como o kernel faria, conferindo a
+// cada componente que o caminho continua dentro de root.
+func resolveLink(root, dir, link string, depth int) (string, error) {
+	if depth > maxLinkDepth || filepath.IsAbs(link) {
+		return "", errLinkEscapes
+	}
+	cur := dir
+	for _, part := range strings.Split(filepath.ToSlash(link), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+		default:
+			next := filepath.Join(cur, part)
+			if info, err := os.Lstat(next); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				dest, err := os.Readlink(next)
+				if err != nil {
+					return "", err
+				}
+				if next, err = resolveLink(root, cur, dest, depth+1); err != nil {
+					return "", err
+				}
+			}
+			cur = next
+		}
+		if !within(root, cur) {
+			return "", errLinkEscapes
+		}
+	}
+	return cur, nil
+}
+
+// resolveInside resolve os symlinks do maior prefixo existente de p e confere
+// que o resultado continua dentro de root.
+func resolveInside(root, p string) (string, error) {
+	existing := p
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			full := filepath.Join(resolved, rest)
+			if !within(root, full) {
+				return "", errLinkEscapes
+			}
+			return full, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+func within(root, p string) bool {
+	p = filepath.Clean(p)
+	return p == root || strings.HasPrefix(p, root+string(os.PathSeparator))
+}
+
+func writeEntry(r io.Reader, target string, hdr *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Nunca escreve atraves de um symlink ja extraido: o alvo dele nao passou
+	// pela verificacao desta entrada.
+	if info, err := os.Lstat(target); err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("bundle: entrada sobrescreveria %s: %s", info.Mode().Type(), hdr.Name)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	mode := os.FileMode(hdr.Mode & 0o755)
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY|syscall.O_NOFOLLOW, mode)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, hdr.Size))
+	closeErr := out.Close()
+	if err != nil {
+		return err
+	}
+	if n != hdr.Size {
+		return fmt.Errorf("bundle: entrada truncada: %s", hdr.Name)
+	}
+	return closeErr
+}
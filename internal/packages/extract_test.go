@@ -0,0 +1,126 @@
+package packages
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+func writeTarGz(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := e.hdr
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.body != "" {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractTarGz_ListsFiles(t *testing.T) {
+	bundle := writeTarGz(t, []tarEntry{
+		{hdr: tar.Header{Name: "zid-proxy/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "zid-proxy/pkg-zid-proxy/install.sh", Typeflag: tar.TypeReg, Mode: 0755}, body: "#!/bin/sh\n"},
+		{hdr: tar.Header{Name: "zid-proxy/current", Typeflag: tar.TypeSymlink, Linkname: "pkg-zid-proxy"}},
+	})
+	root := t.TempDir()
+	files, err := extractTarGz(bundle, root)
+	if err != nil {
+		t.Fatalf("extractTarGz() err=%v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("extractTarGz() files=%d; want 3", len(files))
+	}
+	if files[1].Path != "zid-proxy/pkg-zid-proxy/install.sh" || files[1].Type != "file" || files[1].Size != 10 {
+		t.Fatalf("unexpected entry: %#v", files[1])
+	}
+	if _, err := os.Stat(filepath.Join(root, "zid-proxy", "current", "install.sh")); err != nil {
+		t.Fatalf("symlink inside root should be extracted: %v", err)
+	}
+}
+
+func TestExtractTarGz_RejectsUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{name: "absolute path", entries: []tarEntry{{hdr: tar.Header{Name: "/etc/passwd", Typeflag: tar.TypeReg}, body: "x"}}},
+		{name: "dot dot", entries: []tarEntry{{hdr: tar.Header{Name: "a/../../evil", Typeflag: tar.TypeReg}, body: "x"}}},
+		{name: "absolute symlink", entries: []tarEntry{{hdr: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}}}},
+		{name: "escaping symlink", entries: []tarEntry{{hdr: tar.Header{Name: "a/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}}}},
+		{name: "chained symlink", entries: []tarEntry{
+			{hdr: tar.Header{Name: "d/l", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+			{hdr: tar.Header{Name: "d/l/l2", Typeflag: tar.TypeSymlink, Linkname: ".."}},
+		}},
+		{name: "symlink via root alias", entries: []tarEntry{
+			{hdr: tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."}},
+			{hdr: tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "../x"}},
+		}},
+		{name: "dot dot through extracted symlink", entries: []tarEntry{
+			{hdr: tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755}},
+			{hdr: tar.Header{Name: "a/b/", Typeflag: tar.TypeDir, Mode: 0755}},
+			{hdr: tar.Header{Name: "a/b/u", Typeflag: tar.TypeSymlink, Linkname: "../.."}},
+			{hdr: tar.Header{Name: "a/b/w", Typeflag: tar.TypeSymlink, Linkname: "u/../../../pwned"}},
+			{hdr: tar.Header{Name: "a/b/w", Typeflag: tar.TypeReg}, body: "pwned"},
+		}},
+		{name: "file over symlink", entries: []tarEntry{
+			{hdr: tar.Header{Name: "a/l", Typeflag: tar.TypeSymlink, Linkname: "b"}},
+			{hdr: tar.Header{Name: "a/l", Typeflag: tar.TypeReg}, body: "x"},
+		}},
+		{name: "hardlink escape", entries: []tarEntry{{hdr: tar.Header{Name: "h", Typeflag: tar.TypeLink, Linkname: "../etc/passwd"}}}},
+		{name: "device", entries: []tarEntry{{hdr: tar.Header{Name: "dev", Typeflag: tar.TypeChar}}}},
+		{name: "fifo", entries: []tarEntry{{hdr: tar.Header{Name: "fifo", Typeflag: tar.TypeFifo}}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := t.TempDir()
+			root := filepath.Join(parent, "extract")
+			if err := os.MkdirAll(root, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := extractTarGz(writeTarGz(t, tc.entries), root); err == nil {
+				t.Fatalf("extractTarGz() should reject %s", tc.name)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "x", "evil")); err == nil {
+				t.Fatalf("file written outside extract root")
+			}
+			for dir := parent; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+				if _, err := os.Lstat(filepath.Join(dir, "pwned")); err == nil {
+					t.Fatalf("file written outside extract root: %s", filepath.Join(dir, "pwned"))
+				}
+			}
+		})
+	}
+}
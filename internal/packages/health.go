@@ -0,0 +1,244 @@
+package packages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zid-packages/internal/logx"
+)
+
+const (
+	defaultHealthTimeout = 90 * time.Second
+	healthPollInterval   = 3 * time.Second
+	healthProbeTimeout   = 10 * time.Second
+)
+
+var failedUpdatesPath = "/var/db/zid-packages/failed-updates.json"
+
+// HealthProbe e uma verificacao extra do servico apos update, alem do pgrep:
+// GET em URL (espera 2xx) ou Command (espera exit 0).
+type HealthProbe struct {
+	URL     string   `json:"url,omitempty"`
+	Command []string `json:"command,omitempty"`
+}
+
+// FailedUpdate registra uma versao que falhou no health check pos-update e
+// foi revertida; o auto-update nao tenta de novo essa versao.
+type FailedUpdate struct {
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+	Time    int64  `json:"time"`
+}
+
+// updateWithHealthCheck executa o update e, se algum servico do pacote estava
+// rodando antes, espera que volte. Se nao voltar dentro do timeout, reinstala
+// o bundle anterior do cache e marca a versao nova como falha.
+func updateWithHealthCheck(logger *logx.Logger, pkg Package, update func() error) error {
+	prevVersion := VersionLocal(pkg.Key)
+	running := runningServices(pkg)
+	if err := update(); err != nil {
+		return err
+	}
+	if len(running) == 0 {
+		return nil
+	}
+	newVersion := VersionLocal(pkg.Key)
+	logger.Info("health check: " + pkg.Key + " " + newVersion + " servicos=" + strings.Join(running, ","))
+	herr := waitHealthy(pkg, running, pkg.healthTimeout(), healthPollInterval)
+	if herr == nil {
+		clearFailedUpdate(pkg.Key)
+		logger.Info("health check ok: " + pkg.Key + " " + newVersion)
+		return nil
+	}
+	logger.Error("health check falhou: " + pkg.Key + " " + newVersion + " err=" + herr.Error())
+	recordHistory(HistoryEntry{Package: pkg.Key, Action: "health-check", Version: newVersion}, herr)
+	if err := markFailedUpdate(pkg.Key, newVersion, herr.Error()); err != nil {
+		logger.Error("falha ao gravar versao com falha: " + pkg.Key + " err=" + err.Error())
+	}
+	if rerr := rollbackAfterFailure(logger, pkg, newVersion, prevVersion); rerr != nil {
+		return fmt.Errorf("health check falhou apos update para %s: %v; rollback falhou: %w", newVersion, herr, rerr)
+	}
+	return fmt.Errorf("health check falhou apos update para %s: %v; revertido para %s", newVersion, herr, VersionLocal(pkg.Key))
+}
+
+func rollbackAfterFailure(logger *logx.Logger, pkg Package, failed, prev string) error {
+	bundles, err := CachedBundles(pkg.Key)
+	if err != nil {
+		return err
+	}
+	target, err := selectRollback(bundles, failed, prev)
+	if err != nil && prev != "" {
+		// A versao anterior pode ter sido instalada pelo UpdateCommand (sem
+		// bundle no cache); usa o bundle verificado mais recente.
+		target, err = selectRollback(bundles, failed, "")
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("rollback automatico: " + pkg.Key + " to=" + target.Version)
+	return installAndRecord(logger, pkg, "rollback", func() (installResult, error) {
+		return installLocalBundle(pkg, target.Path)
+	})
+}
+
+func (pkg Package) healthTimeout() time.Duration {
+	if pkg.HealthTimeout > 0 {
+		return time.Duration(pkg.HealthTimeout) * time.Second
+	}
+	return defaultHealthTimeout
+}
+
+func runningServices(pkg Package) []string {
+	var out []string
+	for _, svc := range pkg.Services {
+		if running, _ := ServiceRunning(svc.Key); running {
+			out = append(out, svc.Key)
+		}
+	}
+	return out
+}
+
+// waitHealthy espera todos os servicos em keys rodando e com probe ok. Um
+// servico que caiu e iniciado uma vez, como o watchdog faria: durante o
+// auto-update o loop do daemon esta ocupado aqui.
+func waitHealthy(pkg Package, keys []string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	started := map[string]bool{}
+	for {
+		var lastErr error
+		for _, key := range keys {
+			svc, ok := pkg.service(key)
+			if !ok {
+				continue
+			}
+			err := checkServiceHealth(svc)
+			if err != nil && errors.Is(err, errServiceDown) && !started[key] {
+				started[key] = true
+				_ = StartService(key)
+			}
+			if err != nil {
+				lastErr = err
+				break
+			}
+		}
+		if lastErr == nil {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return lastErr
+		}
+		time.Sleep(interval)
+	}
+}
+
+var errServiceDown = errors.New("servico nao esta rodando")
+
+func checkServiceHealth(svc Service) error {
+	if svc.Pgrep != "" && !pgrepRunning(svc.Pgrep) {
+		return fmt.Errorf("%s: %w", svc.Key, errServiceDown)
+	}
+	if svc.Health == nil {
+		return nil
+	}
+	if err := runHealthProbe(*svc.Health); err != nil {
+		return fmt.Errorf("%s: %w", svc.Key, err)
+	}
+	return nil
+}
+
+func runHealthProbe(probe HealthProbe) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+	if probe.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("health probe: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("health probe: http status %d", resp.StatusCode)
+		}
+	}
+	if len(probe.Command) > 0 {
+		if err := exec.CommandContext(ctx, probe.Command[0], probe.Command[1:]...).Run(); err != nil {
+			return fmt.Errorf("health probe: %s: %w", probe.Command[0], err)
+		}
+	}
+	return nil
+}
+
+func (pkg Package) service(key string) (Service, bool) {
+	for _, svc := range pkg.Services {
+		if svc.Key == key {
+			return svc, true
+		}
+	}
+	return Service{}, false
+}
+
+// FailedUpdateFor devolve a ultima versao revertida por falha no health check.
+func FailedUpdateFor(key string) (FailedUpdate, bool) {
+	all, _ := loadFailedUpdates()
+	f, ok := all[key]
+	return f, ok
+}
+
+func loadFailedUpdates() (map[string]FailedUpdate, error) {
+	out := map[string]FailedUpdate{}
+	data, err := os.ReadFile(failedUpdatesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return out, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]FailedUpdate{}, err
+	}
+	return out, nil
+}
+
+func saveFailedUpdates(all map[string]FailedUpdate) error {
+	if err := os.MkdirAll(filepath.Dir(failedUpdatesPath), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := failedUpdatesPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, failedUpdatesPath)
+}
+
+func markFailedUpdate(key, version, reason string) error {
+	all, _ := loadFailedUpdates()
+	all[key] = FailedUpdate{Version: version, Reason: reason, Time: time.Now().UTC().Unix()}
+	return saveFailedUpdates(all)
+}
+
+func clearFailedUpdate(key string) {
+	all, err := loadFailedUpdates()
+	if err != nil {
+		return
+	}
+	if _, ok := all[key]; !ok {
+		return
+	}
+	delete(all, key)
+	_ = saveFailedUpdates(all)
+}
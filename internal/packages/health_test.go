@@ -0,0 +1,78 @@
+package packages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunHealthProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name    string
+		probe   HealthProbe
+		wantErr bool
+	}{
+		{name: "url ok", probe: HealthProbe{URL: srv.URL + "/health"}},
+		{name: "url 503", probe: HealthProbe{URL: srv.URL + "/down"}, wantErr: true},
+		{name: "command ok", probe: HealthProbe{Command: []string{"/bin/sh", "-c", "exit 0"}}},
+		{name: "command falha", probe: HealthProbe{Command: []string{"/bin/sh", "-c", "exit 3"}}, wantErr: true},
+		{name: "vazio", probe: HealthProbe{}},
+	}
+	for _, tc := range cases {
+		err := runHealthProbe(tc.probe)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: runHealthProbe()=%v; wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestWaitHealthyTimeout(t *testing.T) {
+	pkg := Package{Key: "zid-test", Services: []Service{{
+		Key:    "zid-test",
+		Health: &HealthProbe{Command: []string{"/bin/sh", "-c", "exit 1"}},
+	}}}
+	start := time.Now()
+	if err := waitHealthy(pkg, []string{"zid-test"}, 50*time.Millisecond, 10*time.Millisecond); err == nil {
+		t.Fatalf("waitHealthy()=nil; want erro do probe")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("waitHealthy() nao respeitou o timeout")
+	}
+
+	pkg.Services[0].Health = &HealthProbe{Command: []string{"/bin/sh", "-c", "exit 0"}}
+	if err := waitHealthy(pkg, []string{"zid-test"}, 50*time.Millisecond, 10*time.Millisecond); err != nil {
+		t.Fatalf("waitHealthy()=%v; want nil", err)
+	}
+}
+
+func TestFailedUpdates(t *testing.T) {
+	orig := failedUpdatesPath
+	failedUpdatesPath = filepath.Join(t.TempDir(), "failed-updates.json")
+	defer func() { failedUpdatesPath = orig }()
+
+	if _, ok := FailedUpdateFor("zid-proxy"); ok {
+		t.Fatalf("FailedUpdateFor() sem arquivo; want false")
+	}
+	if err := markFailedUpdate("zid-proxy", "1.4.2", "zid-proxy: servico nao esta rodando"); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := FailedUpdateFor("zid-proxy")
+	if !ok || got.Version != "1.4.2" {
+		t.Fatalf("FailedUpdateFor()=%+v, %v; want 1.4.2", got, ok)
+	}
+	clearFailedUpdate("zid-proxy")
+	if _, ok := FailedUpdateFor("zid-proxy"); ok {
+		t.Fatalf("FailedUpdateFor() apos clear; want false")
+	}
+}
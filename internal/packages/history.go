@@ -0,0 +1,81 @@
+package packages
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const historyPath = "/var/db/zid-packages/install-history.jsonl"
+
+type HistoryEntry struct {
+	Time      int64  `json:"time"`
+	Package   string `json:"package"`
+	Action    string `json:"action"`
+	Version   string `json:"version,omitempty"`
+	BundleURL string `json:"bundle_url,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+}
+
+func recordHistory(entry HistoryEntry, err error) {
+	entry.Time = time.Now().UTC().Unix()
+	entry.Result = "ok"
+	if err != nil {
+		entry.Result = "failed"
+		entry.Error = err.Error()
+	}
+	if werr := appendHistory(historyPath, entry); werr != nil {
+		enableLogger.Error("falha ao gravar historico: " + werr.Error())
+	}
+}
+
+func appendHistory(path string, entry HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// History devolve o historico de instalacoes, do mais antigo para o mais
+// recente. Com key vazia, devolve todos os pacotes.
+func History(key string) ([]HistoryEntry, error) {
+	return readHistory(historyPath, key)
+}
+
+func readHistory(path, key string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	out := []HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if key != "" && entry.Package != key {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out, scanner.Err()
+}
@@ -2,52 +2,188 @@ package packages
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+
+	"zid-packages/internal/download"
+	"zid-packages/internal/s3"
+	"zid-packages/internal/secure"
+)
+
+// downloadsDir e o diretorio de trabalho dos installs (workDir) ficam no
+// mesmo sistema de arquivos: o bundle baixado e movido com rename, que falha
+// com EXDEV entre /var/db e /tmp (tmpfs no pfSense).
+var (
+	downloadsDir = "/var/db/zid-packages/downloads"
+	workDir      = "/var/db/zid-packages/work"
 )
 
-func installBundle(pkg Package) error {
-	if pkg.BundleURL == "" || pkg.InstallScriptGlob == "" {
-		return errors.New("bundle url ou install script nao definido")
+type installResult struct {
+	BundleURL string
+	SHA256    string
+	Version   string
+	Files     []ExtractedFile
+}
+
+func installBundle(pkg Package) (installResult, error) {
+	src, err := resolveBundle(pkg)
+	if err != nil {
+		return installResult{}, err
 	}
-	downloader, err := pickDownloader()
+	res := installResult{BundleURL: src.URL, Version: src.Version}
+	if src.URL == "" || pkg.InstallScriptGlob == "" {
+		return res, errors.New("bundle url ou install script nao definido")
+	}
+	expected, err := expectedDigest(src)
 	if err != nil {
-		return err
+		return res, err
 	}
-	tmpDir, err := os.MkdirTemp("/tmp", "zid-packages-install.")
+	tmpDir, err := newWorkDir()
 	if err != nil {
-		return err
+		return res, err
 	}
 	defer os.RemoveAll(tmpDir)
 
 	bundle := filepath.Join(tmpDir, "bundle.tar.gz")
-	if err := downloadFile(downloader, pkg.BundleURL, bundle); err != nil {
-		return err
+	if err := downloadBundle(pkg.Key, expected, src.URL, bundle, src.Size); err != nil {
+		return res, err
+	}
+	digest, err := verifyFileSHA256(bundle, expected)
+	if err != nil {
+		return res, err
+	}
+	res.SHA256 = digest
+	sig, err := verifyBundleSignature(src.URL, bundle)
+	if err != nil {
+		return res, err
+	}
+	res.Files, err = runBundleInstall(pkg, tmpDir, bundle)
+	if err == nil {
+		res.Version = cacheInstalledBundle(pkg.Key, res.Version, bundle, sig)
+	}
+	return res, err
+}
+
+// installLocalBundle instala um bundle ja presente no disco (ex.: pendrive em
+// firewall sem acesso ao S3). Exige "<arquivo>.sig" ao lado do bundle e, se
+// existir, confere tambem "<arquivo>.sha256"; nada e baixado.
+func installLocalBundle(pkg Package, path string) (installResult, error) {
+	res := installResult{BundleURL: "file://" + path}
+	if pkg.InstallScriptGlob == "" {
+		return res, errors.New("install script nao definido")
+	}
+	sig, digest, err := verifyLocalBundle(path)
+	if err != nil {
+		return res, err
+	}
+	res.SHA256 = digest
+
+	tmpDir, err := newWorkDir()
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(tmpDir)
+	res.Files, err = runBundleInstall(pkg, tmpDir, path)
+	if err == nil {
+		res.Version = cacheInstalledBundle(pkg.Key, res.Version, path, sig)
+	}
+	return res, err
+}
+
+// verifyLocalBundle confere a assinatura do bundle local e, se houver, o
+// "<arquivo>.sha256" assinado; devolve a assinatura e o digest do arquivo.
+func verifyLocalBundle(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sig, err := verifyLocalSignature(path, data)
+	if err != nil {
+		return nil, "", err
+	}
+	digest, err := fileSHA256(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if sidecar, err := os.ReadFile(path + ".sha256"); err == nil {
+		if _, err := verifyLocalSignature(path+".sha256", sidecar); err != nil {
+			return nil, "", err
+		}
+		expected, err := parseDigest(string(sidecar))
+		if err != nil {
+			return nil, "", err
+		}
+		if digest, err = verifyFileSHA256(path, expected); err != nil {
+			return nil, "", err
+		}
+	}
+	return sig, digest, nil
+}
+
+func verifyLocalSignature(path string, data []byte) ([]byte, error) {
+	sig, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s.sig: %v", secure.ErrUnsigned, path, err)
+	}
+	if err := secure.VerifyRelease(data, sig); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sig, nil
+}
+
+// verifyBundleSignature valida o bundle contra "<url>.sig" e devolve a
+// assinatura para guardar junto do bundle no cache local.
+func verifyBundleSignature(url, bundle string) ([]byte, error) {
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := s3.FetchBytes(url+".sig", 4096)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s.sig: %v", secure.ErrUnsigned, url, err)
+	}
+	if err := secure.VerifyRelease(data, sig); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return sig, nil
+}
+
+func newWorkDir() (string, error) {
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return "", err
 	}
+	return os.MkdirTemp(workDir, "install.")
+}
+
+func runBundleInstall(pkg Package, tmpDir, bundle string) ([]ExtractedFile, error) {
 	extractDir := filepath.Join(tmpDir, "extract")
 	if err := os.MkdirAll(extractDir, 0755); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := exec.Command("/usr/bin/tar", "-xzf", bundle, "-C", extractDir).Run(); err != nil {
-		return err
+	files, err := extractTarGz(bundle, extractDir)
+	if err != nil {
+		return files, err
+	}
+	for _, f := range files {
+		fmt.Println("bundle: " + f.Type + " " + f.Path)
 	}
 
 	matches, err := filepath.Glob(filepath.Join(extractDir, pkg.InstallScriptGlob))
 	if err != nil || len(matches) == 0 {
-		return errors.New("install.sh nao encontrado no bundle")
+		return files, errors.New("install.sh nao encontrado no bundle")
 	}
 	installScript := matches[0]
 	if err := os.Chmod(installScript, 0755); err != nil {
-		return err
+		return files, err
 	}
 
 	cmd := exec.Command("/bin/sh", installScript)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return files, cmd.Run()
 }
 
 func runUpdate(cmdPath string) error {
@@ -57,28 +193,45 @@ func runUpdate(cmdPath string) error {
 	return cmd.Run()
 }
 
-func pickDownloader() (string, error) {
-	if _, err := exec.LookPath("fetch"); err == nil {
-		return "fetch", nil
+// downloadBundle baixa em downloadsDir com nome derivado do digest esperado,
+// para que um download interrompido seja retomado na proxima execucao sem
+// misturar versoes diferentes, e move o resultado para dest. size e o tamanho
+// publicado no catalogo (0 quando desconhecido).
+func downloadBundle(key, digest, url, dest string, size int64) error {
+	if err := os.MkdirAll(downloadsDir, 0700); err != nil {
+		return err
 	}
-	if _, err := exec.LookPath("curl"); err == nil {
-		return "curl", nil
+	cached := filepath.Join(downloadsDir, key+"-"+digest[:16]+".tar.gz")
+	if err := downloadFile(url, cached, size); err != nil {
+		return err
 	}
-	return "", errors.New("fetch/curl nao encontrado")
+	return os.Rename(cached, dest)
 }
 
-func downloadFile(downloader, url, dest string) error {
-	if strings.TrimSpace(url) == "" {
-		return errors.New("url vazio")
+// downloadFile limita o download ao tamanho publicado, quando houver; sem
+// ele vale o limite padrao do pacote download.
+func downloadFile(url, dest string, size int64) error {
+	opts := download.DefaultOptions()
+	if size > 0 {
+		opts.MaxSize = size
 	}
-	if downloader == "fetch" {
-		cmd := exec.Command("fetch", "-o", dest, url)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+	opts.Progress = progressPrinter(url)
+	return download.File(url, dest, opts)
+}
+
+// progressPrinter escreve o andamento no stdout (capturado pelo log do update
+// na GUI) a cada 10%.
+func progressPrinter(url string) func(done, total int64) {
+	lastStep := int64(-1)
+	return func(done, total int64) {
+		if total <= 0 {
+			return
+		}
+		step := done * 10 / total
+		if step == lastStep {
+			return
+		}
+		lastStep = step
+		fmt.Printf("download %s: %d%% (%d/%d bytes)\n", url, step*10, done, total)
 	}
-	cmd := exec.Command("curl", "-fL", "-o", dest, url)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
 }
@@ -0,0 +1,126 @@
+package packages
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"zid-packages/internal/download"
+	"zid-packages/internal/lock"
+	"zid-packages/internal/logx"
+)
+
+func TestDownloadFile_RespectsPublishedSize(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name     string
+		size     int64
+		tooLarge bool
+	}{
+		{name: "sem tamanho publicado", size: 0},
+		{name: "tamanho igual", size: 100},
+		{name: "maior que o publicado", size: 10, tooLarge: true},
+	}
+	for _, tc := range cases {
+		dest := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		err := downloadFile(srv.URL+"/bundle.tar.gz", dest, tc.size)
+		var derr *download.Error
+		gotTooLarge := errors.As(err, &derr) && derr.Kind == download.KindTooLarge
+		if gotTooLarge != tc.tooLarge || (!tc.tooLarge && err != nil) {
+			t.Fatalf("%s: downloadFile()=%v; want too_large %v", tc.name, err, tc.tooLarge)
+		}
+	}
+}
+
+func TestUpdate_RefusesUnsignedWithoutOptIn(t *testing.T) {
+	origLockDir := lock.Dir
+	lock.Dir = t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	script := filepath.Join(t.TempDir(), "update.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	descriptorsMu.Lock()
+	descriptorsLoaded = append(loadDescriptors(t.TempDir()), Package{Key: "zid-example", Name: "ZID Example", UpdateCommand: script})
+	descriptorsMu.Unlock()
+	t.Cleanup(func() {
+		lock.Dir = origLockDir
+		ReloadDescriptors()
+	})
+
+	logger := logx.New(filepath.Join(t.TempDir(), "zid-packages.log"))
+	if err := Update(logger, "zid-example", false); !errors.Is(err, ErrUnsignedUpdate) {
+		t.Fatalf("Update()=%v; want ErrUnsignedUpdate", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("update_command ran without --allow-unsigned")
+	}
+}
+
+func TestDownloadBundle_WorkDirBesideCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bundle"))
+	}))
+	defer srv.Close()
+
+	cacheRoot, tmpRoot := t.TempDir(), t.TempDir()
+	origDownloads, origWork := downloadsDir, workDir
+	downloadsDir = filepath.Join(cacheRoot, "downloads")
+	workDir = filepath.Join(cacheRoot, "work")
+	t.Cleanup(func() { downloadsDir, workDir = origDownloads, origWork })
+	t.Setenv("TMPDIR", tmpRoot)
+
+	dir, err := newWorkDir()
+	if err != nil {
+		t.Fatalf("newWorkDir() err=%v", err)
+	}
+	if !strings.HasPrefix(dir, cacheRoot+string(filepath.Separator)) {
+		t.Fatalf("newWorkDir()=%q; want under %q", dir, cacheRoot)
+	}
+	var cacheSt, workSt syscall.Stat_t
+	if err := syscall.Stat(filepath.Dir(downloadsDir), &cacheSt); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Stat(dir, &workSt); err != nil {
+		t.Fatal(err)
+	}
+	if cacheSt.Dev != workSt.Dev {
+		t.Fatalf("work dir em outro sistema de arquivos que o cache de downloads")
+	}
+
+	dest := filepath.Join(dir, "bundle.tar.gz")
+	digest := strings.Repeat("ab", 32)
+	if err := downloadBundle("zid-proxy", digest, srv.URL+"/bundle.tar.gz", dest, 0); err != nil {
+		t.Fatalf("downloadBundle() err=%v", err)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != "bundle" {
+		t.Fatalf("bundle=%q,%v", data, err)
+	}
+	if entries, _ := os.ReadDir(tmpRoot); len(entries) != 0 {
+		t.Fatalf("TMPDIR usado: %v", entries)
+	}
+}
+
+func TestInstallFromFile_InvalidActionTakesNoLock(t *testing.T) {
+	origLockDir := lock.Dir
+	lock.Dir = t.TempDir()
+	t.Cleanup(func() { lock.Dir = origLockDir })
+
+	logger := logx.New(filepath.Join(t.TempDir(), "zid-packages.log"))
+	if err := InstallFromFile(logger, "zid-proxy", "rollback", "/nonexistent.tar.gz"); err == nil {
+		t.Fatalf("InstallFromFile(rollback) should fail")
+	}
+	if entries, _ := os.ReadDir(lock.Dir); len(entries) != 0 {
+		t.Fatalf("lock criado para acao invalida: %v", entries)
+	}
+}
@@ -0,0 +1,26 @@
+package packages
+
+import (
+	"fmt"
+
+	"zid-packages/internal/lock"
+)
+
+// lockPackage coloca o pacote em manutencao: outra operacao no mesmo pacote
+// falha com lock.LockedError e o watchdog ignora os servicos dele.
+func lockPackage(key, op string) (func(), error) {
+	l, err := lock.Acquire(lock.Package(key), op+" "+key)
+	if err != nil {
+		return nil, err
+	}
+	if prev, ok := l.Recovered(); ok {
+		enableLogger.Info(fmt.Sprintf("lock abandonado em %s: %q (pid %d) caiu sem liberar", key, prev.Op, prev.PID))
+	}
+	return func() { _ = l.Release() }, nil
+}
+
+// Maintenance devolve a operacao em andamento no pacote, se houver.
+func Maintenance(key string) (string, bool) {
+	info, held := lock.Held(lock.Package(key))
+	return info.Op, held
+}
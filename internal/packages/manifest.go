@@ -0,0 +1,49 @@
+package packages
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const manifestDir = "/var/db/zid-packages/manifests"
+
+// Manifest registra o conteudo do ultimo bundle instalado de um pacote.
+type Manifest struct {
+	Package     string          `json:"package"`
+	Version     string          `json:"version,omitempty"`
+	BundleURL   string          `json:"bundle_url,omitempty"`
+	SHA256      string          `json:"sha256,omitempty"`
+	InstalledAt int64           `json:"installed_at"`
+	Files       []ExtractedFile `json:"files"`
+}
+
+func saveManifest(m Manifest) error {
+	if err := os.MkdirAll(manifestDir, 0700); err != nil {
+		return err
+	}
+	if m.InstalledAt == 0 {
+		m.InstalledAt = time.Now().UTC().Unix()
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(manifestDir, m.Package+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func LoadManifest(key string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(manifestDir, key+".json"))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
@@ -1,6 +1,11 @@
 package packages
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestServiceFirewallCleanupAction(t *testing.T) {
 	tests := []struct {
@@ -100,8 +105,7 @@ func TestEnableSnapshotZidAccess_HasLegacyKeys(t *testing.T) {
 func TestEnableSnapshotZidOrchestrator_HasRCKeys(t *testing.T) {
 	snap := EnableSnapshot("zid-orchestrator")
 	wantKeys := []string{
-		"rc.conf.local:zid_orchestration_enable",
-		"rc.conf:zid_orchestration_enable",
+		"rc:zid_orchestration_enable",
 	}
 	for _, k := range wantKeys {
 		if _, ok := snap[k]; !ok {
@@ -126,23 +130,104 @@ func TestExtractNumericVersion(t *testing.T) {
 	}
 }
 
-func TestSelectOrchestratorLocalVersion_PrioritizesVersionFile(t *testing.T) {
-	got := selectOrchestratorLocalVersion("0.1.5", "0.1.28", "0.1.27")
+func TestUpdateAvailableWith(t *testing.T) {
+	tests := []struct {
+		local, remote string
+		want          bool
+	}{
+		{local: "1.2.0", remote: "1.2.1", want: true},
+		{local: "1.2.0-rc1", remote: "1.2.0", want: true},
+		{local: "1.2.0", remote: "1.2.0-rc1", want: false},
+		{local: "1.2.0", remote: "1.2.0_1", want: true},
+		{local: "v1.2", remote: "1.2.0", want: false},
+		{local: "dev", remote: "1.2.0", want: false},
+		{local: "", remote: "1.2.0", want: false},
+	}
+	for _, tc := range tests {
+		if got := UpdateAvailableWith(tc.local, tc.remote); got != tc.want {
+			t.Fatalf("UpdateAvailableWith(%q, %q)=%v; want %v", tc.local, tc.remote, got, tc.want)
+		}
+	}
+}
+
+func orchestratorVersionSources(t *testing.T, configVersion, versionFile, binaryVersion string) []VersionSource {
+	t.Helper()
+	dir := t.TempDir()
+	configXMLPath = filepath.Join(dir, "config.xml")
+	t.Cleanup(func() { configXMLPath = "/conf/config.xml" })
+	config := "<pfsense><installedpackages><package><name>zid-orchestration</name><version>" + configVersion + "</version></package></installedpackages></pfsense>"
+	if err := os.WriteFile(configXMLPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := make([]VersionSource, 0, 4)
+	for _, src := range builtinPackage(t, "zid-orchestrator").Version {
+		switch src.Kind {
+		case VersionKindFile:
+			src.File = filepath.Join(dir, "VERSION")
+			if versionFile != "" {
+				if err := os.WriteFile(src.File, []byte(versionFile+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		case VersionKindBinary:
+			src.File = filepath.Join(dir, "zid-orchestration")
+			if binaryVersion != "" {
+				if err := os.WriteFile(src.File, []byte("#!/bin/sh\necho zid-orchestration "+binaryVersion+"\n"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+func builtinPackage(t *testing.T, key string) Package {
+	t.Helper()
+	for _, pkg := range builtin {
+		if pkg.Key == key {
+			return pkg
+		}
+	}
+	t.Fatalf("builtin package %q not found", key)
+	return Package{}
+}
+
+func TestResolveVersionOrchestrator_PrioritizesVersionFile(t *testing.T) {
+	got := resolveVersion(orchestratorVersionSources(t, "0.1.5", "0.1.28", "0.1.27"))
 	if got != "0.1.28" {
-		t.Fatalf("selectOrchestratorLocalVersion()=%q; want %q", got, "0.1.28")
+		t.Fatalf("resolveVersion()=%q; want %q", got, "0.1.28")
 	}
 }
 
-func TestSelectOrchestratorLocalVersion_FallsBackToBinary(t *testing.T) {
-	got := selectOrchestratorLocalVersion("0.1.5", "", "0.1.28")
+func TestResolveVersionOrchestrator_FallsBackToBinary(t *testing.T) {
+	got := resolveVersion(orchestratorVersionSources(t, "0.1.5", "", "0.1.28"))
 	if got != "0.1.28" {
-		t.Fatalf("selectOrchestratorLocalVersion()=%q; want %q", got, "0.1.28")
+		t.Fatalf("resolveVersion()=%q; want %q", got, "0.1.28")
 	}
 }
 
-func TestSelectOrchestratorLocalVersion_ConfigNumericFallback(t *testing.T) {
-	got := selectOrchestratorLocalVersion("zid-orchestration version 0.1.28", "", "")
+func TestResolveVersionOrchestrator_ConfigNumericFallback(t *testing.T) {
+	got := resolveVersion(orchestratorVersionSources(t, "zid-orchestration version 0.1.28", "", ""))
 	if got != "0.1.28" {
-		t.Fatalf("selectOrchestratorLocalVersion()=%q; want %q", got, "0.1.28")
+		t.Fatalf("resolveVersion()=%q; want %q", got, "0.1.28")
+	}
+}
+
+func TestEnableFiles(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{key: "zid-orchestrator", want: []string{"/etc/rc.conf", "/etc/rc.conf.local", "/etc/rc.conf.d/zid_orchestration", "/usr/local/etc/rc.conf.d/zid_orchestration"}},
+		{key: "zid-geolocation", want: []string{configXMLPath, "/usr/local/etc/zid-geolocation/config.json"}},
+		{key: "zid-threatd", want: []string{configXMLPath}},
+		{key: "unknown"},
+	}
+	for _, tc := range tests {
+		got := EnableFiles(tc.key)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("EnableFiles(%s)=%v; want %v", tc.key, got, tc.want)
+		}
 	}
 }
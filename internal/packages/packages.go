@@ -3,80 +3,26 @@ package packages
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"zid-packages/internal/logx"
 )
 
-type Package struct {
-	Key               string
-	Name              string
-	BundleURL         string
-	VersionURL        string
-	UpdateCommand     string
-	InstallScriptGlob string
-}
-
-var all = []Package{
-	{
-		Key:               "zid-packages",
-		Name:              "ZID Packages",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-packages-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-packages-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-packages-update",
-		InstallScriptGlob: "*/scripts/install.sh",
-	},
-	{
-		Key:               "zid-proxy",
-		Name:              "ZID Proxy",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-proxy-pfsense-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-proxy-pfsense-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-proxy-update",
-		InstallScriptGlob: "*/pkg-zid-proxy/install.sh",
-	},
-	{
-		Key:               "zid-geolocation",
-		Name:              "ZID Geolocation",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-geolocation-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-geolocation-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-geolocation-update",
-		InstallScriptGlob: "*/scripts/install.sh",
-	},
-	{
-		Key:               "zid-logs",
-		Name:              "ZID Logs",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-logs-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-logs-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-logs-update",
-		InstallScriptGlob: "*/pkg-zid-logs/install.sh",
-	},
-	{
-		Key:               "zid-access",
-		Name:              "ZID Access",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-access-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-access-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-access-update",
-		InstallScriptGlob: "*/pkg-zid-access/install.sh",
-	},
-	{
-		Key:               "zid-orchestrator",
-		Name:              "ZID Orchestrator",
-		BundleURL:         "https://s3.soulsolucoes.com.br/soul/portal/zid-orchestrator-latest.tar.gz",
-		VersionURL:        "https://s3.soulsolucoes.com.br/soul/portal/zid-orchestrator-latest.version",
-		UpdateCommand:     "/usr/local/sbin/zid-orchestrator-update",
-		InstallScriptGlob: "*/pkg/pfSense-pkg-zid-orchestration/scripts/post-install",
-	},
-}
-
 func All() []Package {
-	return append([]Package(nil), all...)
+	pkgs := descriptors()
+	out := make([]Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		out = append(out, clonePackage(pkg))
+	}
+	return out
 }
 
 func Get(key string) (Package, error) {
 	key = strings.TrimSpace(key)
-	for _, pkg := range all {
+	for _, pkg := range descriptors() {
 		if pkg.Key == key {
-			return pkg, nil
+			return clonePackage(pkg), nil
 		}
 	}
 	return Package{}, fmt.Errorf("unknown package: %s", key)
@@ -87,23 +33,193 @@ func ValidateKey(key string) error {
 	return err
 }
 
+// Install instala o pacote e, antes dele, as dependencias ainda nao instaladas.
 func Install(logger *logx.Logger, key string) error {
 	pkg, err := Get(key)
 	if err != nil {
 		return err
 	}
+	unlock, err := lockPackage(pkg.Key, "install")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prereqs, err := Prerequisites(pkg.Key)
+	if err != nil {
+		return err
+	}
+	for _, dep := range prereqs {
+		if Installed(dep) {
+			continue
+		}
+		depPkg, err := Get(dep)
+		if err != nil {
+			return err
+		}
+		logger.Info("install dependencia: " + dep + " (requerida por " + pkg.Key + ")")
+		unlockDep, err := lockPackage(dep, "install")
+		if err != nil {
+			return fmt.Errorf("dependencia %s: %w", dep, err)
+		}
+		err = installPackage(logger, depPkg)
+		unlockDep()
+		if err != nil {
+			return fmt.Errorf("dependencia %s: %w", dep, err)
+		}
+	}
+	return installPackage(logger, pkg)
+}
+
+func installPackage(logger *logx.Logger, pkg Package) error {
 	logger.Info("install requested: " + pkg.Key)
-	return installBundle(pkg)
+	return installAndRecord(logger, pkg, "install", func() (installResult, error) {
+		return installBundle(pkg)
+	})
+}
+
+// missingPrerequisites lista dependencias ainda nao instaladas (sem rede nao
+// ha como busca-las).
+func missingPrerequisites(key string) ([]string, error) {
+	prereqs, err := Prerequisites(key)
+	if err != nil {
+		return nil, err
+	}
+	missing := []string{}
+	for _, dep := range prereqs {
+		if !Installed(dep) {
+			missing = append(missing, dep)
+		}
+	}
+	return missing, nil
+}
+
+// InstallFromFile instala (action "install") ou atualiza (action "update") a
+// partir de um bundle local, sem acesso a rede.
+func InstallFromFile(logger *logx.Logger, key, action, path string) error {
+	if action != "install" && action != "update" {
+		return fmt.Errorf("acao invalida: %s", action)
+	}
+	pkg, err := Get(key)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPackage(pkg.Key, action)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	missing, err := missingPrerequisites(pkg.Key)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s requer %s; instale antes com --from-file", pkg.Key, strings.Join(missing, ", "))
+	}
+	logger.Info(action + " requested: " + pkg.Key + " from-file=" + path)
+	install := func() error {
+		return installAndRecord(logger, pkg, action, func() (installResult, error) {
+			return installLocalBundle(pkg, path)
+		})
+	}
+	if action == "update" {
+		return updateWithHealthCheck(logger, pkg, install)
+	}
+	return install()
 }
 
-func Update(logger *logx.Logger, key string) error {
+// ErrUnsignedUpdate: o pacote nao tem bundle assinado e o update dependeria
+// do update_command, que nao passa pela verificacao de assinatura.
+var ErrUnsignedUpdate = errors.New("pacote sem bundle assinado; use --allow-unsigned para rodar o update_command")
+
+// Update instala o bundle assinado do pacote. Sem bundle, o update_command so
+// roda com allowUnsigned (opt-in explicito, registrado no log).
+func Update(logger *logx.Logger, key string, allowUnsigned bool) error {
 	pkg, err := Get(key)
 	if err != nil {
 		return err
 	}
+	unlock, err := lockPackage(pkg.Key, "update")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	logger.Info("update requested: " + pkg.Key)
-	if pkg.UpdateCommand == "" {
-		return errors.New("update command not defined")
+	if !SignedBundle(pkg) {
+		// Descritores sem bundle publicado dependem do updater do proprio pacote,
+		// que nao passa pela verificacao de assinatura.
+		if pkg.UpdateCommand == "" {
+			return errors.New("update command not defined")
+		}
+		if !allowUnsigned {
+			return fmt.Errorf("%s: %w", pkg.Key, ErrUnsignedUpdate)
+		}
+		logger.Error("update sem assinatura autorizado (--allow-unsigned): " + pkg.Key + " cmd=" + pkg.UpdateCommand)
+		return updateWithHealthCheck(logger, pkg, func() error {
+			err := runUpdate(pkg.UpdateCommand)
+			version := ""
+			if err == nil {
+				version = VersionLocal(pkg.Key)
+			}
+			recordHistory(HistoryEntry{Package: pkg.Key, Action: "update", Version: version}, err)
+			return err
+		})
+	}
+	return updateWithHealthCheck(logger, pkg, func() error {
+		return installAndRecord(logger, pkg, "update", func() (installResult, error) {
+			return installBundle(pkg)
+		})
+	})
+}
+
+func installAndRecord(logger *logx.Logger, pkg Package, action string, install func() (installResult, error)) error {
+	res, err := install()
+	if res.SHA256 != "" {
+		logger.Info(action + " bundle verificado: " + pkg.Key + " sha256=" + res.SHA256)
+	}
+	if len(res.Files) > 0 {
+		logger.Info(action + " bundle extraido: " + pkg.Key + " arquivos=" + strconv.Itoa(len(res.Files)))
+	}
+	if res.Version == "" && err == nil {
+		res.Version = VersionLocal(pkg.Key)
+	}
+	if err == nil {
+		m := Manifest{Package: pkg.Key, Version: res.Version, BundleURL: res.BundleURL, SHA256: res.SHA256, Files: res.Files}
+		if merr := saveManifest(m); merr != nil {
+			logger.Error("falha ao gravar manifest: " + pkg.Key + " err=" + merr.Error())
+		}
+	}
+	recordHistory(HistoryEntry{Package: pkg.Key, Action: action, Version: res.Version, BundleURL: res.BundleURL, SHA256: res.SHA256}, err)
+	return err
+}
+
+// SignedBundle indica se o pacote e atualizado por bundle assinado (catalogo
+// ou BundleURL do descritor) em vez do UpdateCommand.
+func SignedBundle(pkg Package) bool {
+	return pkg.BundleURL != "" && pkg.InstallScriptGlob != ""
+}
+
+// Rollback reinstala um bundle verificado do cache local: a versao pedida ou,
+// se to for vazio, a mais recente diferente da instalada.
+func Rollback(logger *logx.Logger, key, to string) error {
+	pkg, err := Get(key)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPackage(pkg.Key, "rollback")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	bundles, err := CachedBundles(pkg.Key)
+	if err != nil {
+		return err
+	}
+	target, err := selectRollback(bundles, VersionLocal(pkg.Key), to)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pkg.Key, err)
 	}
-	return runUpdate(pkg.UpdateCommand)
+	logger.Info("rollback requested: " + pkg.Key + " to=" + target.Version)
+	return installAndRecord(logger, pkg, "rollback", func() (installResult, error) {
+		return installLocalBundle(pkg, target.Path)
+	})
 }
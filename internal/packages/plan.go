@@ -0,0 +1,222 @@
+package packages
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"zid-packages/internal/download"
+)
+
+// PlanStep descreve o que install/update/auto-update fariam com um pacote
+// (--dry-run). Error preenchido indica que a acao falharia antes de rodar
+// qualquer script.
+type PlanStep struct {
+	Package   string `json:"package"`
+	Action    string `json:"action"`
+	From      string `json:"version_installed,omitempty"`
+	To        string `json:"version_target,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	Method    string `json:"method,omitempty"`
+	BundleURL string `json:"bundle_url,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+const (
+	PlanInstall = "install"
+	PlanUpdate  = "update"
+	PlanSkip    = "skip"
+)
+
+// statBundle confere a disponibilidade do bundle sem baixa-lo.
+var statBundle = func(url string) (int64, error) {
+	return download.Stat(url, download.DefaultOptions())
+}
+
+func (s PlanStep) String() string {
+	var b strings.Builder
+	b.WriteString(s.Action + " " + s.Package)
+	if s.From != "" || s.To != "" {
+		b.WriteString(" " + orDash(s.From) + " -> " + orDash(s.To))
+	}
+	if s.Method != "" {
+		b.WriteString(" via " + s.Method)
+	}
+	if s.Channel != "" {
+		b.WriteString(" canal=" + s.Channel)
+	}
+	if s.BundleURL != "" {
+		b.WriteString(" url=" + s.BundleURL)
+	}
+	if s.SHA256 != "" {
+		b.WriteString(" sha256=" + s.SHA256)
+	}
+	if s.Size > 0 {
+		b.WriteString(fmt.Sprintf(" size=%d", s.Size))
+	}
+	if s.Reason != "" {
+		b.WriteString(": " + s.Reason)
+	}
+	if s.Error != "" {
+		b.WriteString(" ERRO: " + s.Error)
+	}
+	return b.String()
+}
+
+func orDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+// PlanFailed indica se algum passo do plano falharia.
+func PlanFailed(steps []PlanStep) bool {
+	for _, s := range steps {
+		if s.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanInstallSteps resolve o que Install faria: dependencias ausentes primeiro
+// e depois o proprio pacote.
+func PlanInstallSteps(key string) ([]PlanStep, error) {
+	pkg, err := Get(key)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := missingPrerequisites(pkg.Key)
+	if err != nil {
+		return nil, err
+	}
+	steps := []PlanStep{}
+	for _, dep := range missing {
+		depPkg, err := Get(dep)
+		if err != nil {
+			return nil, err
+		}
+		step := planBundle(depPkg, PlanInstall)
+		step.Reason = "dependencia de " + pkg.Key
+		steps = append(steps, step)
+	}
+	step := planBundle(pkg, PlanInstall)
+	if Installed(pkg.Key) {
+		step.Reason = "ja instalado; o bundle sera reinstalado"
+	}
+	return append(steps, step), nil
+}
+
+// PlanUpdateStep resolve o que Update faria com o pacote.
+func PlanUpdateStep(key string, allowUnsigned bool) (PlanStep, error) {
+	pkg, err := Get(key)
+	if err != nil {
+		return PlanStep{}, err
+	}
+	step := planBundle(pkg, PlanUpdate)
+	if step.Method == "update_command" && step.Error == "" && !allowUnsigned {
+		step.Error = ErrUnsignedUpdate.Error()
+	}
+	return step, nil
+}
+
+// PlanFromFileSteps confere o bundle local como InstallFromFile faria, sem
+// extrair nem rodar o install script.
+func PlanFromFileSteps(key, action, path string) ([]PlanStep, error) {
+	pkg, err := Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if action != PlanInstall && action != PlanUpdate {
+		return nil, fmt.Errorf("acao invalida: %s", action)
+	}
+	step := PlanStep{Package: pkg.Key, Action: action, From: VersionLocal(pkg.Key), Method: "from-file", BundleURL: "file://" + path}
+	if op, held := Maintenance(pkg.Key); held {
+		step.Error = "em manutencao: " + op
+		return []PlanStep{step}, nil
+	}
+	missing, err := missingPrerequisites(pkg.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		step.Error = fmt.Sprintf("%s requer %s; instale antes com --from-file", pkg.Key, strings.Join(missing, ", "))
+		return []PlanStep{step}, nil
+	}
+	return []PlanStep{planLocalBundle(pkg, step, path)}, nil
+}
+
+func planLocalBundle(pkg Package, step PlanStep, path string) PlanStep {
+	if pkg.InstallScriptGlob == "" {
+		step.Error = "install script nao definido"
+		return step
+	}
+	_, digest, err := verifyLocalBundle(path)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	step.SHA256 = digest
+	if info, err := os.Stat(path); err == nil {
+		step.Size = info.Size()
+	}
+	return step
+}
+
+// planBundle faz as mesmas verificacoes de installBundle ate o download:
+// resolve canal e versao, confere o digest publicado (assinado) e a
+// disponibilidade do bundle.
+func planBundle(pkg Package, action string) PlanStep {
+	step := PlanStep{Package: pkg.Key, Action: action, From: VersionLocal(pkg.Key), Channel: Channel(pkg.Key), Method: "bundle"}
+	if op, held := Maintenance(pkg.Key); held {
+		step.Error = "em manutencao: " + op
+		return step
+	}
+	if action == PlanUpdate && !SignedBundle(pkg) {
+		step.Method = "update_command"
+		step.To = VersionRemote(pkg.Key)
+		if pkg.UpdateCommand == "" {
+			step.Error = "update command not defined"
+		}
+		return step
+	}
+	src, err := resolveBundle(pkg)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	step.BundleURL = src.URL
+	step.To = src.Version
+	if step.To == "" {
+		step.To = VersionRemote(pkg.Key)
+	}
+	if src.URL == "" || pkg.InstallScriptGlob == "" {
+		step.Error = "bundle url ou install script nao definido"
+		return step
+	}
+	if step.SHA256, err = expectedDigest(src); err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	size, err := statBundle(src.URL)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	if src.Size > 0 && size >= 0 && size != src.Size {
+		step.Error = fmt.Sprintf("tamanho publicado no catalogo (%d) difere do servidor (%d)", src.Size, size)
+		return step
+	}
+	step.Size = size
+	if size < 0 {
+		step.Size = src.Size
+	}
+	if action == PlanUpdate && step.Reason == "" && !UpdateAvailableWith(step.From, step.To) {
+		step.Reason = "versao publicada nao e mais nova que a instalada; o bundle sera reinstalado"
+	}
+	return step
+}
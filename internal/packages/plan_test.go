@@ -0,0 +1,48 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanStepString(t *testing.T) {
+	tests := []struct {
+		step PlanStep
+		want string
+	}{
+		{
+			step: PlanStep{Package: "zid-proxy", Action: PlanUpdate, From: "1.0.0", To: "1.1.0", Method: "bundle", Channel: "stable", Size: 42},
+			want: "update zid-proxy 1.0.0 -> 1.1.0 via bundle canal=stable size=42",
+		},
+		{
+			step: PlanStep{Package: "zid-logs", Action: PlanInstall, To: "2.0.0", Error: "http status 404"},
+			want: "install zid-logs - -> 2.0.0 ERRO: http status 404",
+		},
+		{
+			step: PlanStep{Package: "zid-access", Action: PlanSkip, Reason: "nao instalado"},
+			want: "skip zid-access: nao instalado",
+		},
+	}
+	for _, tc := range tests {
+		if got := tc.step.String(); got != tc.want {
+			t.Fatalf("String()=%q; want %q", got, tc.want)
+		}
+	}
+}
+
+func TestPlanLocalBundleRequiresSignature(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, []byte("bundle"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg := Package{Key: "zid-proxy", InstallScriptGlob: "*/install.sh"}
+	step := planLocalBundle(pkg, PlanStep{Package: pkg.Key, Action: PlanInstall}, path)
+	if !strings.Contains(step.Error, ".sig") {
+		t.Fatalf("planLocalBundle() error=%q; want missing .sig", step.Error)
+	}
+	if !PlanFailed([]PlanStep{step}) {
+		t.Fatalf("PlanFailed()=false; want true")
+	}
+}
@@ -0,0 +1,244 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Diretorios do rc.conf, na ordem em que o rc.subr do FreeBSD os carrega
+// (load_rc_config): rc.conf, rc.conf.local e depois rc.conf.d/<name> em /etc
+// e em /usr/local/etc. A ultima atribuicao vale.
+var (
+	rcConfDir      = "/etc"
+	localRCConfDir = "/usr/local/etc"
+)
+
+type rcAssign struct {
+	key   string
+	value string
+}
+
+// rcConfFiles lista os arquivos lidos para o rc script name, em ordem de
+// precedencia crescente.
+func rcConfFiles(name string) []string {
+	files := []string{
+		filepath.Join(rcConfDir, "rc.conf"),
+		filepath.Join(rcConfDir, "rc.conf.local"),
+	}
+	if name != "" {
+		files = append(files,
+			filepath.Join(rcConfDir, "rc.conf.d", name),
+			filepath.Join(localRCConfDir, "rc.conf.d", name),
+		)
+	}
+	return files
+}
+
+// lookupRCConf devolve o valor final de key nos arquivos (ultima atribuicao
+// vence) e o arquivo que o definiu. rc.conf.d/<name> pode ser diretorio: os
+// arquivos dele sao lidos em ordem alfabetica, como no rc.subr.
+func lookupRCConf(files []string, key string) (string, string, bool) {
+	value, from, found := "", "", false
+	for _, path := range files {
+		for _, file := range expandRCConfPath(path) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, a := range parseRCConf(string(data)) {
+				if a.key == key {
+					value, from, found = a.value, file, true
+				}
+			}
+		}
+	}
+	return value, from, found
+}
+
+func expandRCConfPath(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return []string{path}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		// Como o glob do rc.subr, ignora ocultos (backups e temporarios).
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			out = append(out, filepath.Join(path, e.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// parseRCConf extrai as atribuicoes de um rc.conf com a sintaxe de shell que
+// aparece na pratica: aspas simples/duplas, escapes, comentarios no fim da
+// linha, continuacao com "\", varias atribuicoes por linha, "export" e ";".
+// Expansoes ($var) nao sao avaliadas e linhas com comandos sao ignoradas.
+func parseRCConf(data string) []rcAssign {
+	var out []rcAssign
+	p := rcParser{src: data}
+	for {
+		words, ok := p.command()
+		if !ok {
+			return out
+		}
+		out = append(out, rcCommandAssigns(words)...)
+	}
+}
+
+// rcWord guarda o texto ja sem aspas e onde estava o primeiro "=" fora delas
+// (-1 sem nenhum); o nome antes dele tambem precisa ter vindo sem aspas.
+type rcWord struct {
+	text     string
+	eq       int
+	quotedAt int
+}
+
+func rcCommandAssigns(words []rcWord) []rcAssign {
+	if len(words) == 0 {
+		return nil
+	}
+	if words[0].eq < 0 && words[0].quotedAt < 0 && (words[0].text == "export" || words[0].text == "readonly") {
+		words = words[1:]
+	}
+	var out []rcAssign
+	for _, w := range words {
+		name, ok := w.assignName()
+		if !ok {
+			// Atribuicoes antes de um comando valem so para ele.
+			return nil
+		}
+		out = append(out, rcAssign{key: name, value: w.text[w.eq+1:]})
+	}
+	return out
+}
+
+func (w rcWord) assignName() (string, bool) {
+	if w.eq <= 0 || (w.quotedAt >= 0 && w.quotedAt < w.eq) {
+		return "", false
+	}
+	name := w.text[:w.eq]
+	for i, r := range name {
+		alpha := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !alpha && (i == 0 || r < '0' || r > '9') {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+type rcParser struct {
+	src string
+	pos int
+}
+
+// command le palavras ate o fim do comando (nova linha, ";" ou comentario).
+// Uma aspa sem fechamento descarta o resto do arquivo, como o sh falharia.
+func (p *rcParser) command() ([]rcWord, bool) {
+	if p.pos >= len(p.src) {
+		return nil, false
+	}
+	var words []rcWord
+	var cur strings.Builder
+	word := rcWord{eq: -1, quotedAt: -1}
+	inWord := false
+	flush := func() {
+		if inWord {
+			word.text = cur.String()
+			words = append(words, word)
+		}
+		cur.Reset()
+		word = rcWord{eq: -1, quotedAt: -1}
+		inWord = false
+	}
+	markQuoted := func() {
+		if word.quotedAt < 0 {
+			word.quotedAt = cur.Len()
+		}
+		inWord = true
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == '\n' || c == ';':
+			flush()
+			return words, true
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		case c == '#' && !inWord:
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '\\':
+			if p.pos >= len(p.src) {
+				continue
+			}
+			next := p.src[p.pos]
+			p.pos++
+			if next == '\n' {
+				continue
+			}
+			markQuoted()
+			cur.WriteByte(next)
+		case c == '\'':
+			markQuoted()
+			end := strings.IndexByte(p.src[p.pos:], '\'')
+			if end < 0 {
+				p.pos = len(p.src)
+				return nil, true
+			}
+			cur.WriteString(p.src[p.pos : p.pos+end])
+			p.pos += end + 1
+		case c == '"':
+			markQuoted()
+			if !p.doubleQuoted(&cur) {
+				return nil, true
+			}
+		default:
+			if c == '=' && word.eq < 0 {
+				word.eq = cur.Len()
+			}
+			inWord = true
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return words, true
+}
+
+func (p *rcParser) doubleQuoted(cur *strings.Builder) bool {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return true
+		case '\\':
+			if p.pos >= len(p.src) {
+				return false
+			}
+			next := p.src[p.pos]
+			switch next {
+			case '$', '`', '"', '\\':
+				cur.WriteByte(next)
+				p.pos++
+			case '\n':
+				p.pos++
+			default:
+				cur.WriteByte(c)
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	p.pos = len(p.src)
+	return false
+}
@@ -0,0 +1,117 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRCConf(t *testing.T) {
+	tests := []struct {
+		data string
+		key  string
+		want string
+		ok   bool
+	}{
+		{`zid_packages_enable="YES"`, "zid_packages_enable", "YES", true},
+		{`zid_packages_enable="YES" # habilitado pela GUI`, "zid_packages_enable", "YES", true},
+		{`zid_packages_enable=YES#x`, "zid_packages_enable", "YES#x", true},
+		{`export zid_packages_enable='YES'`, "zid_packages_enable", "YES", true},
+		{"  zid_packages_enable=\"YES\"\nzid_packages_enable=\"NO\"", "zid_packages_enable", "NO", true},
+		{`a=1; zid_packages_enable=NO b=2`, "zid_packages_enable", "NO", true},
+		{`zid_packages_enable="say \"hi\" \$x"`, "zid_packages_enable", `say "hi" $x`, true},
+		{"zid_packages_enable=\"Y\\\nES\"", "zid_packages_enable", "YES", true},
+		{`zid_packages_enable=Y"E"'S'`, "zid_packages_enable", "YES", true},
+		{`# zid_packages_enable="YES"`, "zid_packages_enable", "", false},
+		{`zid_packages_enable_x="YES"`, "zid_packages_enable", "", false},
+		{`zid_packages_enable=YES /usr/local/sbin/zid-packages daemon`, "zid_packages_enable", "", false},
+		{`"zid_packages_enable"=YES`, "zid_packages_enable", "", false},
+		{"zid_packages_enable=\"YES\nother=1", "zid_packages_enable", "", false},
+		{`zid_packages_enable=`, "zid_packages_enable", "", true},
+	}
+	for _, tt := range tests {
+		got, ok := "", false
+		for _, a := range parseRCConf(tt.data) {
+			if a.key == tt.key {
+				got, ok = a.value, true
+			}
+		}
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("parseRCConf(%q)[%s]=%q,%t; want %q,%t", tt.data, tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func setTestRCConfDirs(t *testing.T) (string, string) {
+	t.Helper()
+	etc, local := t.TempDir(), t.TempDir()
+	oldEtc, oldLocal := rcConfDir, localRCConfDir
+	rcConfDir, localRCConfDir = etc, local
+	t.Cleanup(func() { rcConfDir, localRCConfDir = oldEtc, oldLocal })
+	return etc, local
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRCEnablePrecedence(t *testing.T) {
+	etc, local := setTestRCConfDirs(t)
+	src := RCEnable{Name: "zid_example", Key: "zid_example_enable"}
+	if _, ok := src.Read(); ok {
+		t.Fatalf("Read() ok without files; want false")
+	}
+	steps := []struct {
+		path string
+		data string
+		want string
+		from string
+	}{
+		{filepath.Join(etc, "rc.conf"), `zid_example_enable="YES"`, "true", "rc.conf"},
+		{filepath.Join(etc, "rc.conf.local"), `zid_example_enable="NO"`, "false", "rc.conf.local"},
+		{filepath.Join(etc, "rc.conf.d", "zid_example"), `zid_example_enable="YES"`, "true", "zid_example"},
+		{filepath.Join(local, "rc.conf.d", "zid_example", "10-enable"), `zid_example_enable="NO"`, "false", "10-enable"},
+		{filepath.Join(local, "rc.conf.d", "zid_example", ".10-enable.bak"), `zid_example_enable="YES"`, "false", "10-enable"},
+	}
+	for _, s := range steps {
+		writeTestFile(t, s.path, s.data+"\n")
+		got, ok := src.Read()
+		_, from, _ := lookupRCConf(src.Files(), src.Key)
+		if !ok || got != s.want || filepath.Base(from) != s.from {
+			t.Fatalf("after %s: Read()=%q,%t from %s; want %q from %s", s.path, got, ok, from, s.want, s.from)
+		}
+	}
+}
+
+func TestRCEnableWrite(t *testing.T) {
+	etc, _ := setTestRCConfDirs(t)
+	src := RCEnable{Name: "zid_example", Key: "zid_example_enable"}
+	writeTestFile(t, filepath.Join(etc, "rc.conf"), "zid_example_enable=\"NO\"\n")
+	if err := src.WriteEnabled(true, "test"); err != nil {
+		t.Fatalf("WriteEnabled() err=%v", err)
+	}
+	if got, ok := src.Read(); !ok || got != "true" {
+		t.Fatalf("Read()=%q,%t; want true", got, ok)
+	}
+	if data, _ := os.ReadFile(filepath.Join(etc, "rc.conf")); string(data) != "zid_example_enable=\"NO\"\n" {
+		t.Fatalf("rc.conf=%q; want untouched", data)
+	}
+
+	dropin := filepath.Join(etc, "rc.conf.d", "zid_example")
+	writeTestFile(t, dropin, "zid_example_enable=\"YES\"\n")
+	if err := src.WriteEnabled(false, "test"); err != nil {
+		t.Fatalf("WriteEnabled() err=%v", err)
+	}
+	if data, _ := os.ReadFile(dropin); string(data) != "zid_example_enable=\"NO\"\n" {
+		t.Fatalf("rc.conf.d/zid_example=%q; want disabled there", data)
+	}
+	if got, _ := src.Read(); got != "false" {
+		t.Fatalf("Read()=%q; want false", got)
+	}
+}
@@ -0,0 +1,118 @@
+package packages
+
+// ServiceInfo e a entrada do registro de servicos consumido pelo watchdog,
+// pelo status e pelos comandos "service" do CLI. Vem dos descritores
+// (embutidos + packages.d): um sub-servico novo e declarado so no pacote pai.
+// Start/stop/probe ficam em StartService, StopService e ServiceRunning.
+type ServiceInfo struct {
+	Key         string        `json:"key"`
+	Package     string        `json:"package"`
+	EnableKey   string        `json:"enable_key"`
+	DisplayName string        `json:"name"`
+	Depends     []string      `json:"depends,omitempty"`
+	Supervised  bool          `json:"supervised"`
+	Restart     RestartPolicy `json:"restart"`
+}
+
+// RestartPolicy limita os restarts feitos pelo watchdog: a cada start seguido
+// sem o servico ficar de pe a espera dobra (Backoff ate BackoffMax) e, com
+// MaxRestarts starts dentro de Window, o servico fica parado em crash_loop.
+// Tempos em segundos; campos zerados usam o padrao.
+type RestartPolicy struct {
+	MaxRestarts int `json:"max_restarts,omitempty"`
+	Window      int `json:"window,omitempty"`
+	Backoff     int `json:"backoff,omitempty"`
+	BackoffMax  int `json:"backoff_max,omitempty"`
+}
+
+const (
+	defaultMaxRestarts = 5
+	defaultWindow      = 3600
+	defaultBackoff     = 60
+	defaultBackoffMax  = 900
+)
+
+func (p *RestartPolicy) withDefaults() RestartPolicy {
+	out := RestartPolicy{}
+	if p != nil {
+		out = *p
+	}
+	if out.MaxRestarts <= 0 {
+		out.MaxRestarts = defaultMaxRestarts
+	}
+	if out.Window <= 0 {
+		out.Window = defaultWindow
+	}
+	if out.Backoff <= 0 {
+		out.Backoff = defaultBackoff
+	}
+	if out.BackoffMax < out.Backoff {
+		out.BackoffMax = defaultBackoffMax
+		if out.BackoffMax < out.Backoff {
+			out.BackoffMax = out.Backoff
+		}
+	}
+	return out
+}
+
+// Services lista os servicos de todos os pacotes, na ordem dos descritores.
+func Services() []ServiceInfo {
+	out := []ServiceInfo{}
+	seen := map[string]bool{}
+	for _, pkg := range descriptors() {
+		for _, svc := range pkg.Services {
+			// lookupService resolve a key pelo primeiro pacote; repeticoes em
+			// outro pacote seriam inalcancaveis.
+			if seen[svc.Key] {
+				enableLogger.Error("servico duplicado ignorado: " + svc.Key + " em " + pkg.Key)
+				continue
+			}
+			seen[svc.Key] = true
+			out = append(out, serviceInfo(svc, pkg))
+		}
+	}
+	return out
+}
+
+// LookupServiceInfo devolve a entrada do registro para key.
+func LookupServiceInfo(key string) (ServiceInfo, bool) {
+	svc, pkg, ok := lookupService(key)
+	if !ok {
+		return ServiceInfo{}, false
+	}
+	return serviceInfo(svc, pkg), true
+}
+
+// ServicesForEnable lista os servicos cujo enable e decidido pela cadeia
+// chainKey (ex.: zid-proxy decide zid-proxy e zid-appid).
+func ServicesForEnable(chainKey string) []string {
+	keys := []string{}
+	for _, svc := range Services() {
+		if svc.EnableKey == chainKey {
+			keys = append(keys, svc.Key)
+		}
+	}
+	return keys
+}
+
+func serviceInfo(svc Service, pkg Package) ServiceInfo {
+	// Mesma resolucao de ServiceEnableKey: cadeia propria so vale para servico
+	// com key diferente da do pacote.
+	enableKey := pkg.Key
+	if svc.Key != pkg.Key && len(svc.Enable.Sources) > 0 {
+		enableKey = svc.Key
+	}
+	name := svc.Name
+	if name == "" {
+		name = svc.Key
+	}
+	return ServiceInfo{
+		Key:         svc.Key,
+		Package:     pkg.Key,
+		EnableKey:   enableKey,
+		DisplayName: name,
+		Depends:     append([]string(nil), svc.Depends...),
+		Supervised:  !svc.Unsupervised,
+		Restart:     svc.Restart.withDefaults(),
+	}
+}
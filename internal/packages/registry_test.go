@@ -0,0 +1,74 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServicesBuiltin(t *testing.T) {
+	setTestDescriptors(t, loadDescriptors(t.TempDir()))
+	want := []string{
+		"zid-packages:zid-packages:zid-packages:false",
+		"zid-proxy:zid-proxy:zid-proxy:true",
+		"zid-appid:zid-proxy:zid-proxy:true",
+		"zid-threatd:zid-proxy:zid-threatd:true",
+		"zid-geolocation:zid-geolocation:zid-geolocation:true",
+		"zid-logs:zid-logs:zid-logs:true",
+		"zid-access:zid-access:zid-access:true",
+		"zid-orchestrator:zid-orchestrator:zid-orchestrator:true",
+	}
+	var got []string
+	for _, svc := range Services() {
+		got = append(got, svc.Key+":"+svc.Package+":"+svc.EnableKey+":"+boolString(svc.Supervised))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Services()=%v; want %v", got, want)
+	}
+	if keys := ServicesForEnable("zid-proxy"); strings.Join(keys, ",") != "zid-proxy,zid-appid" {
+		t.Fatalf("ServicesForEnable(zid-proxy)=%v; want [zid-proxy zid-appid]", keys)
+	}
+}
+
+func TestServicesDescriptorSubService(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"key": "zid-logs", "services": [
+		{"key": "zid-logs", "rc_script": "/usr/local/etc/rc.d/zid_logs", "pgrep": "^/usr/local/sbin/zid-logs"},
+		{"key": "zid-logs-shipper", "name": "ZID Logs Shipper", "rc_script": "/usr/local/etc/rc.d/zid_logs_shipper",
+		 "pgrep": "^/usr/local/sbin/zid-logs-shipper", "depends": ["zid-logs"],
+		 "enable": {"sources": [{"kind": "json", "file": "/usr/local/etc/zid-logs/config.json", "key": "ship"}]}}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "zid-logs.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setTestDescriptors(t, loadDescriptors(dir))
+	svc, ok := LookupServiceInfo("zid-logs-shipper")
+	if !ok {
+		t.Fatalf("LookupServiceInfo(zid-logs-shipper) not found")
+	}
+	if svc.Package != "zid-logs" || svc.EnableKey != "zid-logs-shipper" || svc.DisplayName != "ZID Logs Shipper" ||
+		strings.Join(svc.Depends, ",") != "zid-logs" || !svc.Supervised {
+		t.Fatalf("LookupServiceInfo(zid-logs-shipper)=%+v", svc)
+	}
+	if _, ok := LookupServiceInfo("unknown"); ok {
+		t.Fatalf("LookupServiceInfo(unknown) ok; want false")
+	}
+}
+
+func TestRestartPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		in   *RestartPolicy
+		want RestartPolicy
+	}{
+		{nil, RestartPolicy{MaxRestarts: 5, Window: 3600, Backoff: 60, BackoffMax: 900}},
+		{&RestartPolicy{MaxRestarts: 3, Window: 600}, RestartPolicy{MaxRestarts: 3, Window: 600, Backoff: 60, BackoffMax: 900}},
+		{&RestartPolicy{Backoff: 1200}, RestartPolicy{MaxRestarts: 5, Window: 3600, Backoff: 1200, BackoffMax: 1200}},
+		{&RestartPolicy{Backoff: 10, BackoffMax: 40}, RestartPolicy{MaxRestarts: 5, Window: 3600, Backoff: 10, BackoffMax: 40}},
+	}
+	for _, tt := range tests {
+		if got := tt.in.withDefaults(); got != tt.want {
+			t.Fatalf("withDefaults(%+v)=%+v; want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zid-packages/internal/logx"
+)
+
+// Uninstall para os servicos do pacote (StopService ja executa os hooks de
+// cleanup de firewall), roda o uninstall script e remove o manifest. O estado
+// de auto-update e licenca fica a cargo de quem chama.
+func Uninstall(logger *logx.Logger, key string) error {
+	pkg, err := Get(key)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPackage(pkg.Key, "uninstall")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if pkg.Key == "zid-packages" {
+		return errors.New("zid-packages nao pode se desinstalar; use o gerenciador de pacotes do pfSense")
+	}
+	if deps := Dependents(pkg.Key); len(deps) > 0 {
+		return fmt.Errorf("%s ainda e dependencia de: %s", pkg.Key, strings.Join(deps, ", "))
+	}
+	if pkg.UninstallScript == "" {
+		return errors.New("uninstall script nao definido")
+	}
+	if !fileExists(pkg.UninstallScript) {
+		return errors.New("uninstall script nao encontrado: " + pkg.UninstallScript)
+	}
+	logger.Info("uninstall requested: " + pkg.Key)
+	version := VersionLocal(pkg.Key)
+
+	for _, svc := range pkg.Services {
+		if err := StopService(svc.Key); err != nil {
+			logger.Error("uninstall stop falhou: " + svc.Key + " err=" + err.Error())
+		}
+	}
+	err = run("/bin/sh", pkg.UninstallScript)
+	if err == nil {
+		_ = os.Remove(filepath.Join(manifestDir, pkg.Key+".json"))
+		clearFailedUpdate(pkg.Key)
+		logger.Info("uninstall done: " + pkg.Key)
+	} else {
+		logger.Error("uninstall failed: " + pkg.Key + " err=" + err.Error())
+	}
+	recordHistory(HistoryEntry{Package: pkg.Key, Action: "uninstall", Version: version}, err)
+	return err
+}
@@ -0,0 +1,187 @@
+package restarts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"zid-packages/internal/packages"
+)
+
+// StatePath guarda os starts feitos pelo watchdog; e lido tambem pelo status.
+var StatePath = "/var/db/zid-packages/restarts.json"
+
+// StableAfter e quanto tempo o servico precisa ficar rodando depois de um
+// start para a espera voltar ao inicio.
+const StableAfter = 10 * time.Minute
+
+// retrySlack evita que o ciclo de 1 minuto do watchdog, chegando alguns
+// instantes antes de NextRetry, adie o start por um ciclo inteiro.
+const retrySlack = 5 * time.Second
+
+// Entry acompanha um servico: Starts sao os starts do watchdog ainda dentro
+// da janela, Failures os starts seguidos sem o servico ficar de pe.
+type Entry struct {
+	Starts         []int64 `json:"starts,omitempty"`
+	Failures       int     `json:"failures,omitempty"`
+	LastStart      int64   `json:"last_start,omitempty"`
+	NextRetry      int64   `json:"next_retry,omitempty"`
+	CrashLoop      bool    `json:"crash_loop,omitempty"`
+	CrashLoopSince int64   `json:"crash_loop_since,omitempty"`
+}
+
+type State struct {
+	Services map[string]Entry `json:"services"`
+}
+
+type Action int
+
+const (
+	// ActionStart: pode iniciar; o start ja foi registrado.
+	ActionStart Action = iota
+	// ActionWait: ainda dentro da espera (backoff).
+	ActionWait
+	// ActionCrashLoop: atingiu o limite agora; o servico fica parado.
+	ActionCrashLoop
+	// ActionHeld: ja estava em crash_loop.
+	ActionHeld
+)
+
+func Load() (State, error) {
+	data, err := os.ReadFile(StatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return State{Services: map[string]Entry{}}, nil
+		}
+		return State{Services: map[string]Entry{}}, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return State{Services: map[string]Entry{}}, err
+	}
+	if st.Services == nil {
+		st.Services = map[string]Entry{}
+	}
+	return st, nil
+}
+
+func Save(st State) error {
+	if dir := filepath.Dir(StatePath); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	// temporario + rename: o status le o arquivo enquanto o watchdog grava.
+	tmp := StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, StatePath)
+}
+
+// BeforeStart decide se o watchdog pode iniciar key agora e, se puder,
+// registra o start e agenda a proxima tentativa.
+func BeforeStart(st *State, key string, p packages.RestartPolicy, now time.Time) (Action, Entry) {
+	if st.Services == nil {
+		st.Services = map[string]Entry{}
+	}
+	entry := Current(*st, key, p, now)
+	switch {
+	case entry.CrashLoop:
+		return ActionHeld, entry
+	case entry.NextRetry > now.Add(retrySlack).Unix():
+		return ActionWait, entry
+	case len(entry.Starts) >= p.MaxRestarts:
+		entry.CrashLoop = true
+		entry.CrashLoopSince = now.Unix()
+		entry.NextRetry = 0
+		st.Services[key] = entry
+		return ActionCrashLoop, entry
+	}
+	entry.Starts = append(entry.Starts, now.Unix())
+	entry.Failures++
+	entry.LastStart = now.Unix()
+	entry.NextRetry = now.Add(Backoff(p, entry.Failures)).Unix()
+	st.Services[key] = entry
+	return ActionStart, entry
+}
+
+// Running registra que key esta rodando: depois de StableAfter a espera volta
+// ao inicio, e um servico em crash_loop iniciado a mao sai dele. Devolve se o
+// estado mudou.
+func Running(st *State, key string, p packages.RestartPolicy, now time.Time) bool {
+	prev, ok := st.Services[key]
+	if !ok {
+		return false
+	}
+	entry := Current(*st, key, p, now)
+	switch {
+	case entry.CrashLoop:
+		entry = Entry{}
+	case entry.Failures > 0 && now.Sub(time.Unix(entry.LastStart, 0)) >= StableAfter:
+		entry.Failures = 0
+		entry.NextRetry = 0
+	}
+	if len(entry.Starts) == 0 && entry.Failures == 0 {
+		delete(st.Services, key)
+		return true
+	}
+	st.Services[key] = entry
+	return !sameEntry(prev, entry)
+}
+
+// Clear esquece key (servico desabilitado ou sem licenca): ao voltar, comeca
+// do zero, inclusive saindo de crash_loop.
+func Clear(st *State, key string) bool {
+	if _, ok := st.Services[key]; !ok {
+		return false
+	}
+	delete(st.Services, key)
+	return true
+}
+
+// Current devolve a entrada de key com os starts fora da janela descartados.
+func Current(st State, key string, p packages.RestartPolicy, now time.Time) Entry {
+	entry := st.Services[key]
+	cutoff := now.Add(-time.Duration(p.Window) * time.Second).Unix()
+	starts := make([]int64, 0, len(entry.Starts))
+	for _, ts := range entry.Starts {
+		if ts > cutoff {
+			starts = append(starts, ts)
+		}
+	}
+	entry.Starts = starts
+	return entry
+}
+
+// Backoff e a espera apos o start numero failures: Backoff, 2x, 4x... ate
+// BackoffMax.
+func Backoff(p packages.RestartPolicy, failures int) time.Duration {
+	wait := time.Duration(p.Backoff) * time.Second
+	max := time.Duration(p.BackoffMax) * time.Second
+	for i := 1; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
+
+func sameEntry(a, b Entry) bool {
+	if len(a.Starts) != len(b.Starts) {
+		return false
+	}
+	for i := range a.Starts {
+		if a.Starts[i] != b.Starts[i] {
+			return false
+		}
+	}
+	return a.Failures == b.Failures && a.LastStart == b.LastStart && a.NextRetry == b.NextRetry &&
+		a.CrashLoop == b.CrashLoop && a.CrashLoopSince == b.CrashLoopSince
+}
@@ -0,0 +1,126 @@
+package restarts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zid-packages/internal/packages"
+)
+
+var testPolicy = packages.RestartPolicy{MaxRestarts: 5, Window: 3600, Backoff: 60, BackoffMax: 900}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 15 * time.Minute},
+		{10, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(testPolicy, tt.failures); got != tt.want {
+			t.Fatalf("Backoff(%d)=%s; want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestBeforeStartCrashLoop(t *testing.T) {
+	st := State{}
+	start := time.Unix(1700000000, 0)
+	now := start
+	// Starts em ~0, 1, 3, 7 e 15 minutos; em ~30 o limite de 5 na janela estoura.
+	for i := 0; i < 5; i++ {
+		action, entry := BeforeStart(&st, "zid-proxy", testPolicy, now)
+		if action != ActionStart || entry.Failures != i+1 {
+			t.Fatalf("start %d: BeforeStart()=%v,%+v; want start", i+1, action, entry)
+		}
+		next := now.Add(Backoff(testPolicy, i+1))
+		if entry.NextRetry != next.Unix() {
+			t.Fatalf("start %d: NextRetry=%d; want %d", i+1, entry.NextRetry, next.Unix())
+		}
+		if action, _ := BeforeStart(&st, "zid-proxy", testPolicy, next.Add(-time.Minute)); action != ActionWait {
+			t.Fatalf("start %d: BeforeStart() before retry=%v; want wait", i+1, action)
+		}
+		// O ciclo do watchdog chega um pouco antes do horario exato.
+		now = next.Add(-2 * time.Second)
+	}
+	if now.Sub(start) > 31*time.Minute {
+		t.Fatalf("5 starts took %s; want about 30m", now.Sub(start))
+	}
+	action, entry := BeforeStart(&st, "zid-proxy", testPolicy, now)
+	if action != ActionCrashLoop || !entry.CrashLoop || len(entry.Starts) != 5 {
+		t.Fatalf("BeforeStart()=%v,%+v; want crash loop", action, entry)
+	}
+	if action, _ := BeforeStart(&st, "zid-proxy", testPolicy, now.Add(2*time.Hour)); action != ActionHeld {
+		t.Fatalf("BeforeStart() after crash loop=%v; want held", action)
+	}
+	if !Running(&st, "zid-proxy", testPolicy, now) {
+		t.Fatalf("Running() after manual start should change state")
+	}
+	if _, ok := st.Services["zid-proxy"]; ok {
+		t.Fatalf("Services[zid-proxy]=%+v; want cleared after manual start", st.Services["zid-proxy"])
+	}
+}
+
+func TestRunningResetsBackoff(t *testing.T) {
+	st := State{}
+	now := time.Unix(1700000000, 0)
+	BeforeStart(&st, "zid-logs", testPolicy, now)
+	BeforeStart(&st, "zid-logs", testPolicy, now.Add(time.Minute))
+	if Running(&st, "zid-logs", testPolicy, now.Add(5*time.Minute)) {
+		t.Fatalf("Running() before StableAfter changed state")
+	}
+	if !Running(&st, "zid-logs", testPolicy, now.Add(12*time.Minute)) {
+		t.Fatalf("Running() after StableAfter should reset backoff")
+	}
+	entry := st.Services["zid-logs"]
+	if entry.Failures != 0 || entry.NextRetry != 0 || len(entry.Starts) != 2 {
+		t.Fatalf("entry=%+v; want backoff reset and starts kept", entry)
+	}
+	if !Running(&st, "zid-logs", testPolicy, now.Add(2*time.Hour)) {
+		t.Fatalf("Running() after window should drop entry")
+	}
+	if _, ok := st.Services["zid-logs"]; ok {
+		t.Fatalf("Services[zid-logs] kept after window")
+	}
+}
+
+func TestClear(t *testing.T) {
+	st := State{}
+	now := time.Unix(1700000000, 0)
+	BeforeStart(&st, "zid-logs", testPolicy, now)
+	if !Clear(&st, "zid-logs") || Clear(&st, "zid-logs") {
+		t.Fatalf("Clear() should change state only once")
+	}
+	if action, _ := BeforeStart(&st, "zid-logs", testPolicy, now); action != ActionStart {
+		t.Fatalf("BeforeStart() after Clear=%v; want start", action)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	orig := StatePath
+	StatePath = filepath.Join(t.TempDir(), "restarts.json")
+	t.Cleanup(func() { StatePath = orig })
+
+	st := State{Services: map[string]Entry{}}
+	BeforeStart(&st, "zid-logs", testPolicy, time.Unix(1700000000, 0))
+	if err := Save(st); err != nil {
+		t.Fatalf("Save() err=%v", err)
+	}
+	if _, err := os.Stat(StatePath + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Save() left temporary file: %v", err)
+	}
+	got, err := Load()
+	if err != nil {
+		t.Fatalf("Load() err=%v", err)
+	}
+	if !sameEntry(got.Services["zid-logs"], st.Services["zid-logs"]) {
+		t.Fatalf("Load()=%#v; want %#v", got.Services["zid-logs"], st.Services["zid-logs"])
+	}
+}
@@ -1,29 +1,48 @@
 package s3
 
 import (
-	"bufio"
 	"errors"
-	"net/http"
+	"fmt"
 	"strings"
-	"time"
+
+	"zid-packages/internal/download"
+	"zid-packages/internal/secure"
 )
 
+// FetchVersion le um arquivo .version e exige a assinatura de release em
+// "<url>.sig".
 func FetchVersion(url string) (string, error) {
-	if strings.TrimSpace(url) == "" {
-		return "", errors.New("url vazio")
-	}
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+	data, err := FetchBytes(url, 4096)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", errors.New("http status invalido")
+	if err := FetchAndVerify(url, data); err != nil {
+		return "", err
 	}
-	scanner := bufio.NewScanner(resp.Body)
-	if !scanner.Scan() {
+	line, _, _ := strings.Cut(string(data), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
 		return "", errors.New("version vazio")
 	}
-	return strings.TrimSpace(scanner.Text()), nil
+	return line, nil
+}
+
+// FetchAndVerify baixa "<url>.sig" e valida a assinatura de release do
+// conteudo ja baixado de url.
+func FetchAndVerify(url string, payload []byte) error {
+	sig, err := FetchBytes(url+".sig", 4096)
+	if err != nil {
+		return fmt.Errorf("%w: %s.sig: %v", secure.ErrUnsigned, url, err)
+	}
+	if err := secure.VerifyRelease(payload, sig); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	return nil
+}
+
+func FetchBytes(url string, maxBytes int64) ([]byte, error) {
+	opts := download.DefaultOptions()
+	opts.Retries = 2
+	opts.MaxSize = maxBytes
+	return download.Bytes(url, opts)
 }
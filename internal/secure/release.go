@@ -0,0 +1,74 @@
+package secure
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Chaves publicas Ed25519 de release confiaveis, por key ID. As privadas ficam
+// offline. Para rotacionar, publique a nova chave aqui, assine os artefatos com
+// as duas durante a transicao e so entao remova a antiga.
+var trustedReleaseKeys = map[string]string{
+	"zid-release-2026-01": "403a708c3d50c6c3429c08bda6599dd81a493e5bd3ef368ccc7344e0bbefa27b",
+}
+
+var (
+	ErrUnsigned     = errors.New("artefato sem assinatura")
+	ErrBadSignature = errors.New("assinatura invalida")
+)
+
+// VerifyRelease valida um arquivo .sig contra o payload. O .sig tem uma
+// assinatura por linha no formato "<key-id>:<base64>"; basta uma linha de
+// chave confiavel que confira.
+func VerifyRelease(payload []byte, sigFile []byte) error {
+	keys := make(map[string]ed25519.PublicKey, len(trustedReleaseKeys))
+	for id, pubHex := range trustedReleaseKeys {
+		raw, err := hex.DecodeString(pubHex)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[id] = ed25519.PublicKey(raw)
+	}
+	return verifyWithKeys(keys, payload, sigFile)
+}
+
+func TrustedReleaseKeyIDs() []string {
+	out := make([]string, 0, len(trustedReleaseKeys))
+	for id := range trustedReleaseKeys {
+		out = append(out, id)
+	}
+	return out
+}
+
+func verifyWithKeys(keys map[string]ed25519.PublicKey, payload []byte, sigFile []byte) error {
+	found := false
+	for _, line := range strings.Split(string(sigFile), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		found = true
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		pub, trusted := keys[strings.TrimSpace(id)]
+		if !trusted {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			continue
+		}
+		if ed25519.Verify(pub, payload, sig) {
+			return nil
+		}
+	}
+	if !found {
+		return ErrUnsigned
+	}
+	return ErrBadSignature
+}
@@ -0,0 +1,48 @@
+package secure
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestVerifyWithKeys(t *testing.T) {
+	oldPub, oldPriv, _ := ed25519.GenerateKey(nil)
+	newPub, newPriv, _ := ed25519.GenerateKey(nil)
+	_, rogue, _ := ed25519.GenerateKey(nil)
+	payload := []byte("0.4.73\n")
+	line := func(id string, priv ed25519.PrivateKey) string {
+		return id + ":" + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, payload))
+	}
+	keys := map[string]ed25519.PublicKey{"old": oldPub, "new": newPub}
+
+	tests := []struct {
+		name string
+		sig  string
+		want error
+	}{
+		{name: "trusted key", sig: line("new", newPriv)},
+		{name: "rotation keeps both", sig: line("retired", oldPriv) + "\n" + line("old", oldPriv)},
+		{name: "unknown key id", sig: line("rogue", rogue), want: ErrBadSignature},
+		{name: "known id wrong key", sig: line("new", rogue), want: ErrBadSignature},
+		{name: "bare base64", sig: base64.StdEncoding.EncodeToString(ed25519.Sign(newPriv, payload)), want: ErrBadSignature},
+		{name: "empty", sig: "\n", want: ErrUnsigned},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyWithKeys(keys, payload, []byte(tc.sig))
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("verifyWithKeys() err=%v; want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrustedReleaseKeysDecode(t *testing.T) {
+	for id, pubHex := range trustedReleaseKeys {
+		if len(pubHex) != ed25519.PublicKeySize*2 {
+			t.Fatalf("release key %s has invalid length", id)
+		}
+	}
+}
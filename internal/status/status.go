@@ -6,10 +6,12 @@ import (
 	"zid-packages/internal/autoupdate"
 	"zid-packages/internal/licensing"
 	"zid-packages/internal/packages"
+	"zid-packages/internal/restarts"
 )
 
 type PackageStatus struct {
 	Key                     string `json:"key"`
+	Name                    string `json:"name,omitempty"`
 	Installed               bool   `json:"installed"`
 	Enabled                 bool   `json:"enabled"`
 	Licensed                bool   `json:"licensed"`
@@ -23,14 +25,27 @@ type PackageStatus struct {
 	AutoUpdateDueAt         int64  `json:"auto_update_due_at"`
 	RestartPending          bool   `json:"restart_pending"`
 	RestartPendingVersion   string `json:"restart_pending_version,omitempty"`
+	ReleaseNotesURL         string `json:"release_notes_url,omitempty"`
+	RequiresZidPackages     string `json:"requires_zid_packages,omitempty"`
+	Unmanaged               bool   `json:"unmanaged,omitempty"`
+	FailedUpdateVersion     string `json:"failed_update_version,omitempty"`
+	FailedUpdateReason      string `json:"failed_update_reason,omitempty"`
+	Held                    bool   `json:"held"`
+	PinnedVersion           string `json:"pinned_version,omitempty"`
+	Channel                 string `json:"channel,omitempty"`
+	Maintenance             string `json:"maintenance,omitempty"`
 }
 
 type ServiceStatus struct {
-	Key         string `json:"key"`
-	Running     bool   `json:"running"`
-	Enabled     bool   `json:"enabled"`
-	Licensed    bool   `json:"licensed"`
-	DisplayName string `json:"name"`
+	Key            string `json:"key"`
+	Running        bool   `json:"running"`
+	Enabled        bool   `json:"enabled"`
+	Licensed       bool   `json:"licensed"`
+	DisplayName    string `json:"name"`
+	Restarts       int    `json:"restarts"`
+	NextRetryAt    int64  `json:"next_retry_at,omitempty"`
+	CrashLoop      bool   `json:"crash_loop"`
+	CrashLoopSince int64  `json:"crash_loop_since,omitempty"`
 }
 
 type LicensingStatus struct {
@@ -89,8 +104,14 @@ func BuildStatus() Status {
 		if pkg.Key == "zid-packages" {
 			restartPending, restartPendingVersion = packages.RestartPendingInfo()
 		}
+		published, _ := packages.CatalogEntry(pkg.Key)
+		requires, _ := packages.RequiresZidPackages(published)
+		failed, _ := packages.FailedUpdateFor(pkg.Key)
+		hold, held := autoupdate.HoldFor(autoState, pkg.Key)
+		maintenance, _ := packages.Maintenance(pkg.Key)
 		out = append(out, PackageStatus{
 			Key:                     pkg.Key,
+			Name:                    pkg.Name,
 			Installed:               packages.Installed(pkg.Key),
 			Enabled:                 enabled,
 			Licensed:                licensed,
@@ -104,14 +125,40 @@ func BuildStatus() Status {
 			AutoUpdateDueAt:         unixOrZero(autoDueAt),
 			RestartPending:          restartPending,
 			RestartPendingVersion:   restartPendingVersion,
+			ReleaseNotesURL:         published.NotesURL,
+			RequiresZidPackages:     requires,
+			FailedUpdateVersion:     failed.Version,
+			FailedUpdateReason:      failed.Reason,
+			Held:                    held,
+			PinnedVersion:           hold.Version,
+			Channel:                 packages.Channel(pkg.Key),
+			Maintenance:             maintenance,
+		})
+	}
+
+	// Pacotes publicados no catalogo que este binario ainda nao sabe gerenciar.
+	for _, entry := range packages.CatalogOnly() {
+		requires, _ := packages.RequiresZidPackages(entry)
+		out = append(out, PackageStatus{
+			Key:                     entry.Key,
+			Name:                    entry.Name,
+			VersionRemote:           entry.Version,
+			AutoUpdateThresholdDays: autoupdate.ThresholdDays(),
+			ReleaseNotesURL:         entry.NotesURL,
+			RequiresZidPackages:     requires,
+			Unmanaged:               true,
 		})
 	}
 
 	if autoChanged {
-		_ = autoupdate.Save(autoState)
+		keys := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			keys = append(keys, pkg.Key)
+		}
+		_ = autoupdate.SavePackages(autoState, keys, false)
 	}
 
-	services := buildServicesStatus(st.Licensed, licenseOK)
+	services := buildServicesStatus(st.Licensed, licenseOK, now)
 
 	return Status{
 		Packages: out,
@@ -126,35 +173,34 @@ func BuildStatus() Status {
 	}
 }
 
-func buildServicesStatus(licensed map[string]bool, licenseOK bool) []ServiceStatus {
+func buildServicesStatus(licensed map[string]bool, licenseOK bool, now time.Time) []ServiceStatus {
 	services := []ServiceStatus{}
-
-	services = append(services, serviceStatus("zid-packages", "zid-packages", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-proxy", "zid-proxy", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-appid", "zid-proxy", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-threatd", "zid-proxy", "zid-threatd", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-geolocation", "zid-geolocation", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-logs", "zid-logs", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-access", "zid-access", "", licensed, licenseOK))
-	services = append(services, serviceStatus("zid-orchestrator", "zid-orchestrator", "", licensed, licenseOK))
-
+	restartState, _ := restarts.Load()
+	for _, svc := range packages.Services() {
+		services = append(services, serviceStatus(svc, licensed, licenseOK, restartState, now))
+	}
 	return services
 }
 
-func serviceStatus(key, packageKey, enableKey string, licensed map[string]bool, licenseOK bool) ServiceStatus {
-	if enableKey == "" {
-		enableKey = packageKey
+func serviceStatus(svc packages.ServiceInfo, licensed map[string]bool, licenseOK bool, restartState restarts.State, now time.Time) ServiceStatus {
+	enabled, _ := packages.Enabled(svc.EnableKey)
+	running, _ := packages.ServiceRunning(svc.Key)
+	entry := restarts.Current(restartState, svc.Key, svc.Restart, now)
+	out := ServiceStatus{
+		Key:            svc.Key,
+		DisplayName:    svc.DisplayName,
+		Running:        running,
+		Enabled:        enabled,
+		Licensed:       licenseOK && licensed[svc.Package],
+		Restarts:       len(entry.Starts),
+		CrashLoop:      entry.CrashLoop,
+		CrashLoopSince: entry.CrashLoopSince,
 	}
-	enabled, _ := packages.Enabled(enableKey)
-	running, _ := packages.ServiceRunning(key)
-	licensedOK := licenseOK && licensed[packageKey]
-	return ServiceStatus{
-		Key:         key,
-		DisplayName: key,
-		Running:     running,
-		Enabled:     enabled,
-		Licensed:    licensedOK,
+	// A proxima tentativa so interessa enquanto o servico esta parado.
+	if !running && entry.NextRetry > now.Unix() {
+		out.NextRetryAt = entry.NextRetry
 	}
+	return out
 }
 
 func unixOrZero(t time.Time) int64 {
@@ -0,0 +1,201 @@
+// Package version compara versoes de pacotes: semver (prerelease e build
+// metadata), revisao de port do FreeBSD ("_N"), epoch (",N") e "v" inicial.
+package version
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Version struct {
+	Release  []int
+	Pre      []string
+	Build    string
+	Revision int
+	Epoch    int
+}
+
+var (
+	ErrEmpty = errors.New("versao vazia")
+
+	// Em texto livre so rc/beta/alpha contam como prerelease: sufixos como
+	// "-amd64" ou "-freebsd14" sao plataforma, nao parte da versao.
+	tokenRe = regexp.MustCompile(`\bv?\d+(?:\.\d+)+(?:-(?:rc|beta|alpha)(?:\.?\d+)*\b)?(?:\+[0-9A-Za-z.-]+)?(?:_\d+)?(?:,\d+)?`)
+)
+
+// Parse aceita, por exemplo, "1.2.0", "v1.2", "1.2.0-rc1", "1.2.0+git.abc",
+// "1.2.0_3" e "1.2.0_3,1".
+func Parse(s string) (Version, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return Version{}, ErrEmpty
+	}
+	var v Version
+	var err error
+	if i := strings.LastIndexByte(s, ','); i >= 0 {
+		if v.Epoch, err = parseNumber(s[i+1:]); err != nil {
+			return Version{}, fmt.Errorf("versao invalida %q: epoch: %w", raw, err)
+		}
+		s = s[:i]
+	}
+	if i := strings.LastIndexByte(s, '_'); i >= 0 {
+		if v.Revision, err = parseNumber(s[i+1:]); err != nil {
+			return Version{}, fmt.Errorf("versao invalida %q: revisao: %w", raw, err)
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		if v.Build == "" {
+			return Version{}, fmt.Errorf("versao invalida %q: build vazio", raw)
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		if pre == "" {
+			return Version{}, fmt.Errorf("versao invalida %q: prerelease vazio", raw)
+		}
+		v.Pre = strings.Split(pre, ".")
+		for _, id := range v.Pre {
+			if id == "" {
+				return Version{}, fmt.Errorf("versao invalida %q: prerelease vazio", raw)
+			}
+		}
+		s = s[:i]
+	}
+	for _, part := range strings.Split(s, ".") {
+		n, err := parseNumber(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("versao invalida %q: %w", raw, err)
+		}
+		v.Release = append(v.Release, n)
+	}
+	return v, nil
+}
+
+func parseNumber(s string) (int, error) {
+	if s == "" {
+		return 0, errors.New("numero vazio")
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%q nao e numerico", s)
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// Extract devolve a primeira versao encontrada num texto livre (ex.: saida de
+// "-version"), sem o "v" inicial, ou "" se nao houver.
+func Extract(text string) string {
+	match := tokenRe.FindString(text)
+	return strings.TrimPrefix(match, "v")
+}
+
+// Compare devolve -1, 0 ou 1. Build metadata nao conta; prerelease e menor que
+// a release correspondente; a revisao de port so desempata releases iguais.
+func (v Version) Compare(o Version) int {
+	if c := cmpInt(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	n := len(v.Release)
+	if len(o.Release) > n {
+		n = len(o.Release)
+	}
+	for i := 0; i < n; i++ {
+		if c := cmpInt(component(v.Release, i), component(o.Release, i)); c != 0 {
+			return c
+		}
+	}
+	if c := comparePre(v.Pre, o.Pre); c != 0 {
+		return c
+	}
+	return cmpInt(v.Revision, o.Revision)
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v.Release))
+	for i, n := range v.Release {
+		parts[i] = strconv.Itoa(n)
+	}
+	s := strings.Join(parts, ".")
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	if v.Revision > 0 {
+		s += "_" + strconv.Itoa(v.Revision)
+	}
+	if v.Epoch > 0 {
+		s += "," + strconv.Itoa(v.Epoch)
+	}
+	return s
+}
+
+// Compare compara duas versoes em texto; ok e false se alguma for invalida.
+func Compare(a, b string) (int, bool) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, false
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, false
+	}
+	return va.Compare(vb), true
+}
+
+func component(parts []int, i int) int {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return 0
+}
+
+func comparePre(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aerr := parseNumber(a[i])
+		bn, berr := parseNumber(b[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if c := cmpInt(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "1.2.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.0-rc1", "1.2.0", -1},
+		{"1.2.0-beta.2", "1.2.0-beta.11", -1},
+		{"1.2.0-alpha", "1.2.0-alpha.1", -1},
+		{"1.2.0-alpha.1", "1.2.0-alpha.beta", -1},
+		{"1.2.0-rc.1", "1.1.9", 1},
+		{"1.2.0+git.abc", "1.2.0+git.def", 0},
+		{"1.2.0_3", "1.2.0_2", 1},
+		{"1.2.0_1", "1.2.0", 1},
+		{"1.2.0_9", "1.2.1", -1},
+		{"1.0,1", "2.0", 1},
+		{"1.2.0-rc1_2", "1.2.0", -1},
+	}
+	for _, tc := range cases {
+		got, ok := Compare(tc.a, tc.b)
+		if !ok || got != tc.want {
+			t.Fatalf("Compare(%q, %q)=%d,%v; want %d", tc.a, tc.b, got, ok, tc.want)
+		}
+		if back, _ := Compare(tc.b, tc.a); back != -tc.want {
+			t.Fatalf("Compare(%q, %q)=%d; want %d", tc.b, tc.a, back, -tc.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, in := range []string{"", "dev", "1..2", "1.2-", "1.2_x", "1.2,", "1.2+", "1.2.0-rc..1", "abc 1.2"} {
+		if v, err := Parse(in); err == nil {
+			t.Fatalf("Parse(%q)=%v; want erro", in, v)
+		}
+	}
+}
+
+func TestParse_String(t *testing.T) {
+	for _, in := range []string{"1.2.0", "1.2.0-rc.1+build.5_3,1", "0.4.12_2"} {
+		v, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q) err=%v", in, err)
+		}
+		if got := v.String(); got != in {
+			t.Fatalf("Parse(%q).String()=%q; want %q", in, got, in)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"zid-proxy v1.4.2\nbuilt 2026-01-01", "1.4.2"},
+		{"zid-logs 0.3.0-rc1 (freebsd/amd64)", "0.3.0-rc1"},
+		{"pfSense-pkg-zid-proxy-1.4.2_3", "1.4.2_3"},
+		{"zid-orchestration 0.1.28-amd64", "0.1.28"},
+		{"zid-geolocation 1.2.0-freebsd14", "1.2.0"},
+		{"zid-proxy 1.5.0-beta.2 (freebsd14)", "1.5.0-beta.2"},
+		{"zid-access 2.0.0-alpha-amd64", "2.0.0-alpha"},
+		{"zid-logs 1.0.0-rcfoo", "1.0.0"},
+		{"no version here", ""},
+	}
+	for _, tc := range cases {
+		if got := Extract(tc.in); got != tc.want {
+			t.Fatalf("Extract(%q)=%q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package watchdog
+
+import (
+	"sort"
+	"time"
+
+	"zid-packages/internal/fswatch"
+	"zid-packages/internal/logx"
+	"zid-packages/internal/packages"
+)
+
+// Um save na GUI gera varios eventos (escrita, rename, backup do config.xml);
+// a reconciliacao espera o arquivo ficar quieto.
+const watchDebounce = 2 * time.Second
+
+// watchConfigFiles observa os arquivos das cadeias de enable (config.xml,
+// rc.conf*, JSONs dos pacotes) e entrega, ja com debounce, as keys dos
+// servicos afetados. Sem suporte a fswatch o canal e nil e so o ticker age.
+func watchConfigFiles(logger *logx.Logger) (<-chan []string, func()) {
+	byFile := servicesByFile()
+	if len(byFile) == 0 {
+		return nil, func() {}
+	}
+	w, err := fswatch.New()
+	if err != nil {
+		logger.Info("watchdog: observacao de arquivos indisponivel: " + err.Error())
+		return nil, func() {}
+	}
+	// Diretorios que ainda nao existem (rc.conf.d) sao observados a partir do
+	// ancestral existente; rc.conf.d/<name> pode ser diretorio e, nesse caso,
+	// os arquivos dentro dele tambem contam.
+	for file := range byFile {
+		if err := w.Add(file); err != nil {
+			logger.Info("watchdog: nao foi possivel observar " + file + ": " + err.Error())
+		}
+	}
+	out := make(chan []string)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		debounce(w.Events(), byFile, watchDebounce, out, stop)
+	}()
+	return out, func() {
+		close(stop)
+		_ = w.Close()
+		<-done
+	}
+}
+
+// servicesByFile mapeia cada arquivo de enable para os servicos que dependem
+// dele.
+func servicesByFile() map[string][]string {
+	out := map[string][]string{}
+	for _, svc := range supervised() {
+		for _, file := range packages.EnableFiles(svc.EnableKey) {
+			out[file] = append(out[file], svc.Key)
+		}
+	}
+	return out
+}
+
+// debounce junta os servicos afetados pelos eventos e os entrega quando nao
+// chega evento novo por wait.
+func debounce(events <-chan string, byFile map[string][]string, wait time.Duration, out chan<- []string, stop <-chan struct{}) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(wait)
+	timer.Stop()
+	for {
+		select {
+		case file, ok := <-events:
+			if !ok {
+				return
+			}
+			affected := byFile[file]
+			if len(affected) == 0 {
+				continue
+			}
+			for _, key := range affected {
+				pending[key] = true
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+		case <-timer.C:
+			keys := make([]string, 0, len(pending))
+			for key := range pending {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			pending = map[string]bool{}
+			select {
+			case out <- keys:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	events := make(chan string)
+	out := make(chan []string)
+	stop := make(chan struct{})
+	defer close(stop)
+	byFile := map[string][]string{
+		"/conf/config.xml":   {"zid-proxy", "zid-threatd"},
+		"/etc/rc.conf.local": {"zid-orchestrator"},
+	}
+	go debounce(events, byFile, 50*time.Millisecond, out, stop)
+
+	events <- "/conf/config.xml"
+	events <- "/etc/unrelated"
+	events <- "/conf/config.xml"
+	events <- "/etc/rc.conf.local"
+	select {
+	case keys := <-out:
+		if got := strings.Join(keys, ","); got != "zid-orchestrator,zid-proxy,zid-threatd" {
+			t.Fatalf("debounce()=%q; want all affected services once", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("debounce() nao entregou")
+	}
+
+	events <- "/etc/unrelated"
+	select {
+	case keys := <-out:
+		t.Fatalf("debounce() entregou %v para arquivo sem servico", keys)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestServicesByFile(t *testing.T) {
+	byFile := servicesByFile()
+	got := strings.Join(byFile["/etc/rc.conf.local"], ",")
+	if !strings.Contains(got, "zid-orchestrator") {
+		t.Fatalf("servicesByFile()[rc.conf.local]=%q; want zid-orchestrator", got)
+	}
+	if len(byFile["/conf/config.xml"]) == 0 {
+		t.Fatalf("servicesByFile() sem config.xml")
+	}
+}
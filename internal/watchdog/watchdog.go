@@ -5,15 +5,19 @@ import (
 	"os"
 	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"zid-packages/internal/autoupdate"
 	"zid-packages/internal/ipc"
+	"zid-packages/internal/jobs"
 	"zid-packages/internal/licensing"
+	"zid-packages/internal/lock"
 	"zid-packages/internal/logx"
 	"zid-packages/internal/packages"
+	"zid-packages/internal/restarts"
 )
 
 var ErrDaemonStopped = errors.New("daemon stopped")
@@ -23,24 +27,55 @@ const (
 	licenseInterval  = 2 * time.Hour
 )
 
-type service struct {
-	Key         string
-	PackageKey  string
-	EnableKey   string
-	DisplayName string
+// supervised lista, do registro de servicos, os que o watchdog gerencia.
+func supervised() []packages.ServiceInfo {
+	out := []packages.ServiceInfo{}
+	for _, svc := range packages.Services() {
+		if svc.Supervised {
+			out = append(out, svc)
+		}
+	}
+	return out
 }
 
-var services = []service{
-	{Key: "zid-proxy", PackageKey: "zid-proxy", DisplayName: "zid-proxy"},
-	{Key: "zid-appid", PackageKey: "zid-proxy", DisplayName: "zid-appid"},
-	{Key: "zid-threatd", PackageKey: "zid-proxy", EnableKey: "zid-threatd", DisplayName: "zid-threatd"},
-	{Key: "zid-geolocation", PackageKey: "zid-geolocation", DisplayName: "zid-geolocation"},
-	{Key: "zid-logs", PackageKey: "zid-logs", DisplayName: "zid-logs"},
-	{Key: "zid-access", PackageKey: "zid-access", DisplayName: "zid-access"},
-	{Key: "zid-orchestrator", PackageKey: "zid-orchestrator", DisplayName: "zid-orchestrator"},
+func RunOnce(logger *logx.Logger) error {
+	return reconcile(logger, supervised())
 }
 
-func RunOnce(logger *logx.Logger) error {
+// RunServices reconcilia so os servicos informados (ex.: apos mudanca no
+// config.xml vista pelo watcher do daemon).
+func RunServices(logger *logx.Logger, keys []string) error {
+	want := map[string]bool{}
+	for _, key := range keys {
+		want[key] = true
+	}
+	selected := []packages.ServiceInfo{}
+	for _, svc := range supervised() {
+		if want[svc.Key] {
+			selected = append(selected, svc)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return reconcile(logger, selected)
+}
+
+func reconcile(logger *logx.Logger, selected []packages.ServiceInfo) error {
+	// Cron e daemon nao agem ao mesmo tempo, nem durante o auto-update.
+	l, err := lock.Acquire(lock.Global, "watchdog")
+	if err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			logger.Info("watchdog ignorado: " + err.Error())
+			return nil
+		}
+		return err
+	}
+	defer l.Release()
+	if prev, ok := l.Recovered(); ok {
+		logger.Info("watchdog: lock global abandonado por " + strconv.Quote(prev.Op) + " pid=" + strconv.Itoa(prev.PID))
+	}
+
 	now := time.Now().UTC()
 	st, err := licensing.LoadState()
 	if err != nil {
@@ -56,21 +91,59 @@ func RunOnce(logger *logx.Logger) error {
 	mode, _ := licensing.Evaluate(st, now)
 	licenseOK := mode == licensing.ModeOK || mode == licensing.ModeOfflineGrace
 
-	for _, svc := range services {
-		if !packages.Installed(svc.PackageKey) {
+	restartState, err := restarts.Load()
+	if err != nil {
+		logger.Error("falha ao carregar restarts: " + err.Error())
+	}
+	restartsChanged := false
+	defer func() {
+		if restartsChanged {
+			if err := restarts.Save(restartState); err != nil {
+				logger.Error("falha ao gravar restarts: " + err.Error())
+			}
+		}
+	}()
+
+	for _, svc := range selected {
+		if !packages.Installed(svc.Package) {
 			continue
 		}
-		enableKey := svc.PackageKey
-		if svc.EnableKey != "" {
-			enableKey = svc.EnableKey
+		if op, busy := packages.Maintenance(svc.Package); busy {
+			logger.Info("watchdog ignorado: " + svc.DisplayName + " em manutencao (" + op + ")")
+			continue
 		}
+		enableKey := svc.EnableKey
 		enabled, _ := packages.Enabled(enableKey)
-		licensed := licenseOK && st.Licensed[svc.PackageKey]
+		licensed := licenseOK && st.Licensed[svc.Package]
 		shouldRun := enabled && licensed
 
 		running, _ := packages.ServiceRunning(svc.Key)
+		switch {
+		case !shouldRun:
+			restartsChanged = restarts.Clear(&restartState, svc.Key) || restartsChanged
+		case running:
+			restartsChanged = restarts.Running(&restartState, svc.Key, svc.Restart, now) || restartsChanged
+		}
 		if shouldRun && !running {
-			logger.Info("watchdog start: " + svc.DisplayName + watchdogReason(enabled, licensed, mode))
+			if dep, ok := waitingDependency(svc.Key); ok {
+				// Dependencia em crash_loop nao volta sozinha: nao repete o aviso.
+				if !restartState.Services[dep].CrashLoop {
+					logger.Info("watchdog start adiado: " + svc.DisplayName + " aguardando " + dep)
+				}
+				continue
+			}
+			action, entry := restarts.BeforeStart(&restartState, svc.Key, svc.Restart, now)
+			switch action {
+			case restarts.ActionWait, restarts.ActionHeld:
+				continue
+			case restarts.ActionCrashLoop:
+				restartsChanged = true
+				logger.Error("watchdog crash_loop: " + svc.DisplayName + " " + strconv.Itoa(len(entry.Starts)) +
+					" starts em " + strconv.Itoa(svc.Restart.Window) + "s; mantido parado ate ser desabilitado/habilitado ou iniciado manualmente")
+				continue
+			}
+			restartsChanged = true
+			logger.Info("watchdog start: " + svc.DisplayName + watchdogReason(enabled, licensed, mode) + restartInfo(entry, now))
 			_ = packages.StartService(svc.Key)
 		}
 		if !shouldRun && running {
@@ -93,7 +166,18 @@ func RunDaemon(logger *logx.Logger, interval time.Duration) error {
 	// significa que o admin aplicou a manutencao/restart. Limpa o marcador.
 	packages.ClearRestartPending()
 
+	jobManager := jobs.NewManager(jobs.Dir, logger, packageJobCommand)
+	if err := jobManager.Start(); err != nil {
+		logger.Error("jobs indisponiveis: " + err.Error())
+		jobManager = nil
+	} else {
+		defer jobManager.Stop()
+	}
+
 	ipcServer := ipc.NewServer(logger)
+	if jobManager != nil {
+		ipcServer.SetJobManager(jobManager)
+	}
 	if err := ipcServer.Start(); err != nil {
 		return err
 	}
@@ -107,6 +191,9 @@ func RunDaemon(logger *logx.Logger, interval time.Duration) error {
 	logger.Info("daemon start")
 	_ = RunOnce(logger)
 
+	changes, stopWatch := watchConfigFiles(logger)
+	defer stopWatch()
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
 
@@ -119,6 +206,9 @@ func RunDaemon(logger *logx.Logger, interval time.Duration) error {
 			if autoupdate.ShouldRunNow(autoState, nowLocal, autoupdate.ScheduleHour, autoupdate.ScheduleMinute) {
 				autoupdate.RunOnce(logger, nowLocal)
 			}
+		case keys := <-changes:
+			logger.Info("watchdog: configuracao alterada; reconciliando " + strings.Join(keys, ", "))
+			_ = RunServices(logger, keys)
 		case <-licenseTicker.C:
 			_ = licensing.Sync(logger)
 		case <-sigs:
@@ -128,6 +218,38 @@ func RunDaemon(logger *logx.Logger, interval time.Duration) error {
 	}
 }
 
+// waitingDependency devolve o primeiro servico requerido por key que nao esta
+// rodando; o start fica para o proximo ciclo.
+func waitingDependency(key string) (string, bool) {
+	for _, dep := range packages.ServiceDependencies(key) {
+		if running, _ := packages.ServiceRunning(dep); !running {
+			return dep, true
+		}
+	}
+	return "", false
+}
+
+// packageJobCommand executa o job como "zid-packages package <acao> <pkg>";
+// com ZID_PACKAGES_JOB no ambiente o CLI roda a operacao localmente.
+func packageJobCommand(job jobs.Job) []string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "/usr/local/sbin/zid-packages"
+	}
+	return append([]string{exe, "package", job.Action, job.Package}, job.Args...)
+}
+
+// restartInfo descreve, a partir do segundo start seguido, a tentativa e
+// quando o watchdog tentara de novo se o servico cair.
+func restartInfo(entry restarts.Entry, now time.Time) string {
+	if entry.Failures <= 1 {
+		return ""
+	}
+	wait := time.Unix(entry.NextRetry, 0).Sub(now) / time.Second
+	return " tentativa=" + strconv.Itoa(entry.Failures) + " starts_na_janela=" + strconv.Itoa(len(entry.Starts)) +
+		" proxima_em=" + strconv.FormatInt(int64(wait), 10) + "s"
+}
+
 func watchdogReason(enabled bool, licensed bool, mode string) string {
 	return " (enabled=" + boolLabel(enabled) + " licensed=" + boolLabel(licensed) + " mode=" + mode + ")"
 }